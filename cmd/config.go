@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"text/tabwriter"
+
+	"github.com/CafeKetab/user/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// ConfigFlag is the persistent flag of the root command holding the path of
+// the YAML or TOML configuration file.
+const ConfigFlag = "config"
+
+func configFile(command *cobra.Command) string {
+	path, _ := command.Flags().GetString(ConfigFlag)
+	return path
+}
+
+type Config struct {
+	format string
+}
+
+func (c Config) Command() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "inspect the effective configuration",
+	}
+
+	print := &cobra.Command{
+		Use:   "print",
+		Short: "print the configuration, redacted, with the source of every key",
+		Args:  cobra.NoArgs,
+		Run:   c.print,
+	}
+	print.Flags().StringVarP(&c.format, "format", "f", config.FormatYAML, "output format, yaml or json")
+
+	command.AddCommand(
+		print,
+		&cobra.Command{
+			Use:   "validate",
+			Short: "validate the configuration and report all of its errors",
+			Args:  cobra.NoArgs,
+			Run:   c.validate,
+		},
+		&cobra.Command{
+			Use:   "env",
+			Short: "list the environment variables with their defaults",
+			Args:  cobra.NoArgs,
+			Run:   c.env,
+		},
+	)
+
+	return command
+}
+
+func (c *Config) print(command *cobra.Command, _ []string) {
+	cfg, sources, err := config.ReadSources(configFile(command))
+	if err != nil {
+		log.Fatalf("error loading config:\n%v", err)
+	}
+
+	if err := config.Print(command.OutOrStdout(), cfg, sources, c.format); err != nil {
+		log.Fatalf("error printing config: %v", err)
+	}
+}
+
+func (c *Config) validate(command *cobra.Command, _ []string) {
+	if _, err := config.Read(configFile(command)); err != nil {
+		log.Fatalf("invalid config:\n%v", err)
+	}
+
+	fmt.Fprintln(command.OutOrStdout(), "config is valid")
+}
+
+func (c *Config) env(command *cobra.Command, _ []string) {
+	vars, err := config.EnvVars()
+	if err != nil {
+		log.Fatalf("error listing environment variables: %v", err)
+	}
+
+	writer := tabwriter.NewWriter(command.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "VARIABLE\tDEFAULT")
+	for _, v := range vars {
+		fmt.Fprintf(writer, "%s\t%s\n", v.Name, v.Default)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("error listing environment variables: %v", err)
+	}
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"os"
+	"strconv"
 
 	"github.com/CafeKetab/user/internal/config"
 	"github.com/CafeKetab/user/internal/repository"
@@ -12,47 +15,170 @@ import (
 	"go.uber.org/zap"
 )
 
-type Migrate struct{}
+type Migrate struct {
+	dryRun    bool
+	directory string
+}
+
+const migrateUsage = `Actions:
+  up          apply every pending migration
+  down        revert every applied migration
+  status      list the migrations and whether they have been applied
+  version     print the current version and whether it's dirty
+  goto N      apply or revert the migrations until version N
+  steps N     apply the next N migrations, or revert the last -N ones
+  force N     set the version to N without migrating, to recover a dirty database
+  create NAME add empty up and down migrations of every dialect to --dir
+
+Flags go before the action, e.g. "migrate --dry-run steps -2", since
+negative numbers would be taken for flags otherwise.`
+
+// actionArgs is the number of arguments every action takes
+var actionArgs = map[string]int{
+	"up": 0, "down": 0, "status": 0, "version": 0,
+	"goto": 1, "steps": 1, "force": 1, "create": 1,
+}
 
 func (m Migrate) Command(trap chan os.Signal) *cobra.Command {
-	run := func(_ *cobra.Command, args []string) {
-		m.main(config.Load(true), args, trap)
+	run := func(command *cobra.Command, args []string) {
+		if args[0] == "create" {
+			m.create(args[1])
+			return
+		}
+
+		m.main(config.Load(configFile(command), true), args, trap)
+	}
+
+	validate := func(_ *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("an action is required")
+		}
+
+		count, ok := actionArgs[args[0]]
+		if !ok {
+			return fmt.Errorf("invalid action %q", args[0])
+		} else if len(args)-1 != count {
+			return fmt.Errorf("action %q accepts %d arg(s), received %d", args[0], count, len(args)-1)
+		}
+
+		return nil
 	}
 
-	return &cobra.Command{
-		Use:       "migrate",
+	command := &cobra.Command{
+		Use:       "migrate [flags] ACTION [N|NAME]",
 		Short:     "run user migrations",
+		Long:      migrateUsage,
 		Run:       run,
-		Args:      cobra.OnlyValidArgs,
-		ValidArgs: []string{"up", "down"},
+		Args:      validate,
+		ValidArgs: []string{"up", "down", "status", "version", "goto", "steps", "force", "create"},
 	}
+
+	command.Flags().SetInterspersed(false)
+	command.Flags().BoolVar(&m.dryRun, "dry-run", false, "print the SQL of the migrations instead of running them")
+	command.Flags().StringVar(&m.directory, "dir", "internal/repository/migrations", "migrations directory of create")
+
+	return command
 }
 
 func (m *Migrate) main(cfg *config.Config, args []string, trap chan os.Signal) {
-	logger := logger.NewZap(cfg.Logger)
-
-	if len(args) != 1 {
-		logger.Fatal("invalid arguments given", zap.Any("args", args))
+	logger, err := logger.NewZap(cfg.Logger)
+	if err != nil {
+		log.Fatalf("Error creating logger\n%v", err)
 	}
 
-	rdbms, err := rdbms.NewPostgres(cfg.RDBMS)
+	rdbms, err := rdbms.New(cfg.RDBMS)
 	if err != nil {
 		logger.Fatal("Error creating rdbms", zap.Error(err))
 	}
 
-	repository := repository.New(logger, rdbms)
-	repository.MigrateUp(context.Background())
+	migrator := repository.NewMigrator(logger, rdbms)
+	ctx := context.Background()
 
-	var callsMigrator func(context.Context) error
-	if args[0] == "up" {
-		callsMigrator = repository.MigrateUp
-	} else {
-		callsMigrator = repository.MigrateDown
+	var number int
+	if len(args) == 2 {
+		if number, err = strconv.Atoi(args[1]); err != nil {
+			logger.Fatal("Error invalid number given", zap.String("action", args[0]), zap.String("number", args[1]))
+		}
 	}
 
-	if err := callsMigrator(context.Background()); err != nil {
+	var steps []repository.Step
+	switch args[0] {
+	case "status":
+		m.status(ctx, logger, migrator)
+		return
+	case "version":
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			logger.Fatal("Error reading migration version", zap.Error(err))
+		}
+
+		fmt.Printf("version: %d\ndirty: %t\n", version, dirty)
+		return
+	case "force":
+		if err := migrator.Force(ctx, number); err != nil {
+			logger.Fatal("Error forcing migration version", zap.Int("version", number), zap.Error(err))
+		}
+
+		logger.Info("Migration version has been forced successfully", zap.Int("version", number))
+		return
+	case "up":
+		steps, err = migrator.Up(ctx, m.dryRun)
+	case "down":
+		steps, err = migrator.Down(ctx, m.dryRun)
+	case "steps":
+		steps, err = migrator.Steps(ctx, number, m.dryRun)
+	case "goto":
+		if number < 0 {
+			logger.Fatal("Error invalid version given", zap.Int("version", number))
+		}
+		steps, err = migrator.Goto(ctx, uint(number), m.dryRun)
+	}
+
+	if err != nil {
 		logger.Fatal("Error migrate database", zap.String("migration", args[0]), zap.Error(err))
 	}
 
-	logger.Info("Database has been migrated successfully", zap.String("migration", args[0]))
+	if m.dryRun {
+		for _, step := range steps {
+			fmt.Printf("-- %06d_%s.%s.sql\n%s\n", step.Version, step.Name, step.Direction, step.Query)
+		}
+		return
+	}
+
+	logger.Info("Database has been migrated successfully", zap.String("migration", args[0]), zap.Int("steps", len(steps)))
+}
+
+func (m *Migrate) status(ctx context.Context, logger *zap.Logger, migrator repository.Migrator) {
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		logger.Fatal("Error reading migration version", zap.Error(err))
+	}
+
+	migrations, err := migrator.Status(ctx)
+	if err != nil {
+		logger.Fatal("Error reading migrations status", zap.Error(err))
+	}
+
+	fmt.Printf("version: %d, dirty: %t\n", version, dirty)
+	for _, migration := range migrations {
+		state := "pending"
+		if migration.Applied {
+			state = "applied"
+		}
+
+		fmt.Printf("%06d  %-8s %s\n", migration.Version, state, migration.Name)
+	}
+}
+
+// create needs no database, the files are added to the source tree and embedded by the next build
+func (m *Migrate) create(name string) {
+	files, err := repository.CreateMigration(m.directory, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating migration: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, file := range files {
+		fmt.Println(file)
+	}
 }
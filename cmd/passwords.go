@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/CafeKetab/user/internal/config"
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/rdbms"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type Passwords struct{}
+
+func (p Passwords) Command(trap chan os.Signal) *cobra.Command {
+	run := func(command *cobra.Command, args []string) {
+		p.main(config.Load(configFile(command), true), args, trap)
+	}
+
+	return &cobra.Command{
+		Use:       "passwords",
+		Short:     "maintain stored user passwords",
+		Long:      "flag-legacy: force users whose password is stored in plaintext to reset it",
+		Run:       run,
+		Args:      cobra.OnlyValidArgs,
+		ValidArgs: []string{"flag-legacy"},
+	}
+}
+
+func (p *Passwords) main(cfg *config.Config, args []string, trap chan os.Signal) {
+	logger, err := logger.NewZap(cfg.Logger)
+	if err != nil {
+		log.Fatalf("Error creating logger\n%v", err)
+	}
+
+	if len(args) != 1 {
+		logger.Fatal("invalid arguments given", zap.Any("args", args))
+	}
+
+	rdbms, err := rdbms.New(cfg.RDBMS)
+	if err != nil {
+		logger.Fatal("Error creating rdbms", zap.Error(err))
+	}
+
+	repository := repository.New(logger, rdbms)
+	if err := repository.FlagLegacyPasswords(context.Background()); err != nil {
+		logger.Fatal("Error flagging legacy passwords", zap.Error(err))
+	}
+
+	logger.Info("Legacy passwords have been flagged for reset successfully")
+}
@@ -1,15 +1,29 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"log"
 	"os"
 
+	"github.com/CafeKetab/user/internal/api/admin"
 	"github.com/CafeKetab/user/internal/api/grpc"
 	"github.com/CafeKetab/user/internal/api/http"
+	"github.com/CafeKetab/user/internal/auth"
 	"github.com/CafeKetab/user/internal/config"
+	"github.com/CafeKetab/user/internal/lockout"
+	"github.com/CafeKetab/user/internal/policy"
 	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/hasher"
+	"github.com/CafeKetab/user/pkg/health"
+	"github.com/CafeKetab/user/pkg/lifecycle"
 	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/mailer"
 	"github.com/CafeKetab/user/pkg/rdbms"
+	"github.com/CafeKetab/user/pkg/secretbox"
+	"github.com/CafeKetab/user/pkg/tracing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -17,8 +31,9 @@ import (
 type Server struct{}
 
 func (cmd Server) Command(trap chan os.Signal) *cobra.Command {
-	run := func(_ *cobra.Command, _ []string) {
-		cmd.main(config.Load(true), trap)
+	run := func(command *cobra.Command, _ []string) {
+		path := configFile(command)
+		cmd.main(path, config.Load(path, true), trap)
 	}
 
 	return &cobra.Command{
@@ -28,21 +43,156 @@ func (cmd Server) Command(trap chan os.Signal) *cobra.Command {
 	}
 }
 
-func (cmd *Server) main(cfg *config.Config, trap chan os.Signal) {
-	logger := logger.NewZap(cfg.Logger)
+func (cmd *Server) main(path string, cfg *config.Config, trap chan os.Signal) {
+	logger, err := logger.NewZap(cfg.Logger)
+	if err != nil {
+		log.Fatalf("Error creating logger\n%v", err)
+	}
+
+	tracing, err := tracing.New(cfg.Tracing)
+	if err != nil {
+		logger.Panic("Error creating tracing", zap.Error(err))
+	}
 
-	rdbms, err := rdbms.NewPostgres(cfg.RDBMS)
+	rdbms, err := rdbms.New(cfg.RDBMS)
 	if err != nil {
 		logger.Panic("Error creating rdbms database", zap.Error(err))
 	}
 
+	hasher, err := hasher.New(cfg.Hasher)
+	if err != nil {
+		logger.Panic("Error creating password hasher", zap.Error(err))
+	}
+
+	passwordPolicy := policy.NewPasswordPolicy(cfg.PasswordPolicy)
+
+	var lockoutStore lockout.Store
+	switch cfg.Lockout.Store {
+	case lockout.StoreMemory:
+		lockoutStore = lockout.NewMemoryStore()
+	case lockout.StoreRDBMS:
+		lockoutStore = lockout.NewRDBMSStore(rdbms)
+	default:
+		logger.Panic("Error invalid lockout store", zap.String("store", cfg.Lockout.Store))
+	}
+	lockoutTracker := lockout.NewTracker(cfg.Lockout, lockoutStore)
+
+	mailer, err := mailer.New(cfg.Mailer, logger)
+	if err != nil {
+		logger.Panic("Error creating mailer", zap.Error(err))
+	}
+
+	secretbox, err := secretbox.New(cfg.Encryption)
+	if err != nil {
+		logger.Panic("Error creating secret box", zap.Error(err))
+	}
+
 	repo := repository.New(logger, rdbms)
+	migrator := repository.NewMigrator(logger, rdbms)
+	if cfg.RDBMS.InMemory() {
+		if _, err := migrator.Up(context.Background(), false); err != nil {
+			logger.Panic("Error migrating the in-memory database", zap.Error(err))
+		}
+	}
 	authGrpcClient := grpc.NewAuthClient(cfg.GRPC, logger)
 
-	server := http.New(cfg.HTTP, logger, repo, authGrpcClient)
-	go server.Serve()
+	authenticator, err := auth.New(cfg.Auth, logger, authGrpcClient)
+	if err != nil {
+		logger.Panic("Error creating authenticator", zap.Error(err))
+	}
+
+	// the hooks are stopped in reverse, so the servers are drained before the connections are closed
+	manager := lifecycle.New(cfg.Lifecycle, logger)
+	manager.Append(lifecycle.Hook{Name: "tracing", OnStop: tracing.Shutdown})
+
+	readiness := health.New(cfg.Health, logger, manager.Ready)
+	readiness.Register("rdbms", rdbms.Ping)
+	readiness.Register("auth_grpc", authGrpcClient.Check)
+	readiness.Register("migrations", migrator.Check)
+
+	manager.Append(lifecycle.Hook{
+		Name:   "rdbms",
+		OnStop: func(context.Context) error { return rdbms.Close() },
+	})
+	manager.Append(lifecycle.Hook{
+		Name:   "auth grpc client",
+		OnStop: func(context.Context) error { return authGrpcClient.Close() },
+	})
+
+	watcher := config.NewWatcher(path, cfg, logger)
+	subscribe(watcher, passwordPolicy, lockoutTracker)
+	manager.Append(lifecycle.Hook{Name: "config watcher", OnStart: watcher.Start, OnStop: watcher.Stop})
+
+	prometheus.MustRegister(rdbms.Collector())
+	if cfg.Admin.ListenPort != 0 {
+		adminServer := admin.New(cfg.Admin, logger)
+		manager.Append(lifecycle.Hook{
+			Name: "admin server",
+			OnStart: func(context.Context) error {
+				manager.Go("admin server", adminServer.Serve)
+				return nil
+			},
+			OnStop: adminServer.Shutdown,
+		})
+	}
+
+	if cfg.GRPC.ListenPort != 0 {
+		grpcServer := grpc.NewServer(
+			cfg.GRPC, logger, repo, hasher, lockoutTracker, readiness, cfg.HTTP.Verification.Required,
+		)
+		manager.Append(lifecycle.Hook{
+			Name: "grpc server",
+			OnStart: func(context.Context) error {
+				manager.Go("grpc server", grpcServer.Serve)
+				return nil
+			},
+			OnStop: grpcServer.Shutdown,
+		})
+	}
+
+	server := http.New(
+		cfg.HTTP, logger, repo, authGrpcClient, authenticator,
+		hasher, passwordPolicy, lockoutTracker, mailer, secretbox, readiness,
+		manager,
+	)
+	manager.Append(lifecycle.Hook{
+		Name: "http server",
+		OnStart: func(context.Context) error {
+			manager.Go("http server", server.Serve)
+			return nil
+		},
+		OnStop: server.Shutdown,
+	})
+
+	if err := manager.Start(context.Background()); err != nil {
+		logger.Fatal("Error starting the server", zap.Error(err))
+	}
+
+	reason := manager.Wait(trap)
+
+	logger.Info("shutting down", zap.Duration("timeout", cfg.Lifecycle.ShutdownTimeout))
+	if err := errors.Join(reason, manager.Stop()); err != nil {
+		logger.Fatal("Error shutting down the server", zap.Error(err))
+	}
+
+	logger.Info("server has been shut down gracefully")
+}
+
+// subscribe applies the changes of the reloadable settings to the components
+func subscribe(watcher *config.Watcher, passwordPolicy policy.PasswordPolicy, lockoutTracker lockout.Tracker) {
+	config.Subscribe(watcher, func(cfg *config.Config) string { return cfg.Logger.Level }, logger.SetLevel)
+
+	config.Subscribe(watcher, func(cfg *config.Config) *policy.Config { return cfg.PasswordPolicy },
+		func(cfg *policy.Config) error {
+			passwordPolicy.Update(cfg)
+			return nil
+		},
+	)
 
-	// Keep this at the bottom of the main function
-	field := zap.String("signal trap", (<-trap).String())
-	logger.Info("exiting by receiving a unix signal", field)
+	config.Subscribe(watcher, func(cfg *config.Config) *lockout.Config { return cfg.Lockout },
+		func(cfg *lockout.Config) error {
+			lockoutTracker.Update(cfg)
+			return nil
+		},
+	)
 }
@@ -0,0 +1,16 @@
+package admin
+
+type Config struct {
+	// ListenHost of the admin server, the loopback by default so only the
+	// operators of the host reach it.
+	ListenHost string `koanf:"listen_host"`
+
+	// ListenPort of the admin server, it's kept off the public port since the
+	// endpoints are for operators only, the server is disabled when it is zero.
+	ListenPort int `koanf:"listen_port"`
+
+	// Token is the bearer token of the requests changing the state, like the
+	// PUT of /log/level, the reads are open. It's required unless the server
+	// listens on the loopback.
+	Token string `koanf:"token" redact:"true"`
+}
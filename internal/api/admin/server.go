@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// Server serves the operational endpoints, /metrics and /log/level among them
+type Server struct {
+	config *Config
+	logger *zap.Logger
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+func New(cfg *Config, lg *zap.Logger) *Server {
+	server := &Server{config: cfg, logger: lg, mux: http.NewServeMux()}
+	server.server = &http.Server{
+		Addr:    net.JoinHostPort(cfg.ListenHost, strconv.Itoa(cfg.ListenPort)),
+		Handler: server.authorize(server.mux),
+	}
+
+	server.mux.Handle("/metrics", promhttp.Handler())
+	server.mux.Handle("/log/level", logger.LevelHandler())
+
+	return server
+}
+
+// Handle adds an endpoint to the admin server, it must be called before Serve
+func (server *Server) Handle(pattern string, handler http.Handler) {
+	server.mux.Handle(pattern, handler)
+}
+
+// authorize requires the bearer Token for the requests other than GET and
+// HEAD, they change the state of the server. Without a token, which is only
+// allowed on the loopback, every request is accepted.
+func (server *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || len(server.config.Token) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(server.config.Token)) != 1 {
+			server.logger.Error("Admin token is missing or invalid", zap.String("path", r.URL.Path), zap.String("ip", r.RemoteAddr))
+			http.Error(w, "missing or invalid authorization token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve blocks until the server is shut down, it returns nil afterwards
+func (server *Server) Serve() error {
+	if err := server.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		server.logger.Error("error resolving admin server", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for the requests in flight until the deadline of ctx
+func (server *Server) Shutdown(ctx context.Context) error {
+	return server.server.Shutdown(ctx)
+}
@@ -3,39 +3,126 @@ package grpc
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	pb "github.com/CafeKetab/PBs/golang/auth"
+	"github.com/CafeKetab/user/pkg/logger"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+var ErrInvalidToken = errors.New("token has been rejected by the auth service")
+
 type AuthClient interface {
 	GenerateToken(ctx context.Context, id uint64) (string, error)
+
+	// IdFromToken returns ErrInvalidToken when the auth service rejects the token
+	IdFromToken(ctx context.Context, token string) (uint64, error)
+
+	// Check fails unless the connection to the auth service is ready, an idle
+	// connection is asked to connect and waited for until ctx is done.
+	Check(ctx context.Context) error
+
+	// Close closes the connection to the auth service
+	Close() error
 }
 
+// authClient logs with the logger of the context, so the errors carry the id of the request
 type authClient struct {
-	logger *zap.Logger
-	api    pb.AuthClient
+	connection *grpc.ClientConn
+	api        pb.AuthClient
 }
 
 func NewAuthClient(cfg *Config, lg *zap.Logger) *authClient {
-	client := &authClient{logger: lg}
+	client := &authClient{}
 
-	connection, err := grpc.Dial(cfg.AuthGrpcClientAddress, grpc.WithInsecure())
+	// the interceptors propagate the trace and the request id of ctx to the auth service
+	connection, err := grpc.Dial(
+		cfg.AuthGrpcClientAddress,
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor(), requestIdInterceptor),
+	)
 	if err != nil {
 		lg.Panic("error while instantiating auth grpc client", zap.Error(err))
 	}
+	client.connection = connection
 	client.api = pb.NewAuthClient(connection)
 
 	return client
 }
 
+// RequestIdMetadata is the metadata key the request id is forwarded with
+const RequestIdMetadata = "x-request-id"
+
+func requestIdInterceptor(
+	ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+) error {
+	if id := logger.RequestId(ctx); len(id) != 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIdMetadata, id)
+	}
+
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
 func (c *authClient) GenerateToken(ctx context.Context, id uint64) (string, error) {
+	start := time.Now()
 	pbToken, err := c.api.CreateTokenFromId(ctx, &pb.Id{Value: id})
+	authClientDuration.WithLabelValues("GenerateToken").Observe(time.Since(start).Seconds())
 	if err != nil {
+		authClientFailures.WithLabelValues("GenerateToken").Inc()
+
 		errString := "Error generating token for given id"
-		c.logger.Error(errString, zap.Uint64("id", id), zap.Error(err))
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
 		return "", errors.New(errString)
 	}
 	return pbToken.Value, nil
 }
+
+func (c *authClient) IdFromToken(ctx context.Context, token string) (uint64, error) {
+	start := time.Now()
+	pbId, err := c.api.GetIdFromToken(ctx, &pb.Token{Value: token})
+	authClientDuration.WithLabelValues("IdFromToken").Observe(time.Since(start).Seconds())
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unauthenticated, codes.InvalidArgument, codes.NotFound, codes.PermissionDenied:
+			return 0, ErrInvalidToken
+		}
+
+		authClientFailures.WithLabelValues("IdFromToken").Inc()
+
+		errString := "Error getting id from the given token"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return 0, errors.New(errString)
+	}
+	return pbId.Value, nil
+}
+
+func (c *authClient) Check(ctx context.Context) error {
+	state := c.connection.GetState()
+	if state == connectivity.Idle {
+		c.connection.Connect()
+	}
+
+	for state == connectivity.Idle || state == connectivity.Connecting {
+		if !c.connection.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("auth grpc connection is %s: %w", state, ctx.Err())
+		}
+		state = c.connection.GetState()
+	}
+
+	if state != connectivity.Ready {
+		return fmt.Errorf("auth grpc connection is %s", state)
+	}
+
+	return nil
+}
+
+func (c *authClient) Close() error {
+	return c.connection.Close()
+}
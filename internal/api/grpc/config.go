@@ -1,5 +1,28 @@
 package grpc
 
+import "time"
+
 type Config struct {
 	AuthGrpcClientAddress string `koanf:"auth_grpc_client_address"`
+
+	// ListenPort of the user grpc server, the server is disabled when it is zero
+	ListenPort int `koanf:"listen_port"`
+
+	// AuthToken is the bearer token the callers send in the authorization
+	// metadata, the health service is open. It's required by the server.
+	AuthToken string `koanf:"auth_token" redact:"true"`
+
+	// TrustedProxies are the addresses or CIDR ranges of the gateways whose
+	// x-forwarded-for metadata is trusted, the ip of the other callers is
+	// their own.
+	TrustedProxies []string `koanf:"trusted_proxies"`
+
+	// DefaultTimeout is applied to the calls which arrive without a deadline
+	DefaultTimeout time.Duration `koanf:"default_timeout"`
+
+	// Reflection serves the schema of the services to the authenticated callers
+	Reflection bool `koanf:"reflection"`
+
+	// HealthInterval is how often the readiness checks update the grpc health service
+	HealthInterval time.Duration `koanf:"health_interval"`
 }
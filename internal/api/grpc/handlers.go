@@ -0,0 +1,173 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/CafeKetab/user/internal/models"
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/logger"
+	pb "github.com/CafeKetab/user/pkg/pb/user"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func (server *Server) GetUser(ctx context.Context, id *pb.Id) (*pb.User, error) {
+	if id.Value == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id has been given")
+	}
+
+	user, err := server.repository.FindUserById(ctx, id.Value)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user with given id doesn't exists")
+		}
+
+		logger.FromContext(ctx).Error("Error while retrieving the user", zap.Uint64("id", id.Value), zap.Error(err))
+		return nil, status.Error(codes.Internal, "error while retrieving the user")
+	}
+
+	return toProto(user), nil
+}
+
+// maxBatchSize keeps the placeholders of the IN list under the limits of the databases
+const maxBatchSize = 1000
+
+// GetUsersByIds returns the existing users of the given ids, the missing ids are skipped
+func (server *Server) GetUsersByIds(ctx context.Context, ids *pb.Ids) (*pb.Users, error) {
+	if len(ids.Values) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids can be given", maxBatchSize)
+	}
+
+	result, err := server.repository.FindUsersByIds(ctx, ids.Values)
+	if err != nil {
+		logger.FromContext(ctx).Error("Error while retrieving the users", zap.Int("count", len(ids.Values)), zap.Error(err))
+		return nil, status.Error(codes.Internal, "error while retrieving the users")
+	}
+
+	users := &pb.Users{Values: make([]*pb.User, 0, len(result))}
+	for _, user := range result {
+		users.Values = append(users.Values, toProto(user))
+	}
+
+	return users, nil
+}
+
+func (server *Server) GetUserByEmail(ctx context.Context, email *pb.Email) (*pb.User, error) {
+	if len(email.Value) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid email has been given")
+	}
+
+	user, err := server.repository.FindUserByEmail(ctx, email.Value)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user with given email doesn't exists")
+		}
+
+		logger.FromContext(ctx).Error("Error while retrieving the user", zap.Error(err))
+		return nil, status.Error(codes.Internal, "error while retrieving the user")
+	}
+
+	return toProto(user), nil
+}
+
+// ValidateCredentials checks the email and password with the same lockout
+// rules as the http login, it does not issue any token. The accounts with
+// two-factor authentication, and the unverified ones when the verification is
+// required, are rejected with FailedPrecondition since the password alone does
+// not log them in.
+func (server *Server) ValidateCredentials(ctx context.Context, credentials *pb.Credentials) (*pb.User, error) {
+	ip := server.clientIP(ctx)
+
+	wait, err := server.lockout.Check(ctx, credentials.Email, ip)
+	if err != nil {
+		logger.FromContext(ctx).Error("Error while checking the login attempts", zap.Error(err))
+		return nil, status.Error(codes.Internal, "error while checking the login attempts")
+	} else if wait > 0 {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many failed attempts, retry after %s", wait)
+	}
+
+	user, err := server.repository.FindUserByEmail(ctx, credentials.Email)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		logger.FromContext(ctx).Error("Error while retrieving the user", zap.Error(err))
+		return nil, status.Error(codes.Internal, "error while retrieving the user")
+	}
+
+	if user == nil {
+		// hashing anyway keeps the registered emails from being told apart by the response time
+		server.hasher.VerifyDummy(credentials.Password)
+		server.failAttempt(ctx, credentials.Email, ip)
+		return nil, status.Error(codes.Unauthenticated, "wrong email or password has been given")
+	}
+
+	if ok, err := server.hasher.Verify(credentials.Password, user.Password); err != nil || !ok {
+		server.failAttempt(ctx, credentials.Email, ip)
+		return nil, status.Error(codes.Unauthenticated, "wrong email or password has been given")
+	}
+
+	// checked after the password, so the flag of an account is not disclosed to anyone
+	if user.PasswordResetRequired {
+		return nil, status.Error(codes.FailedPrecondition, "password reset is required for this account")
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, status.Error(codes.FailedPrecondition, "two-factor authentication is required for this account")
+	}
+
+	if server.verificationRequired && user.EmailVerifiedAt == nil {
+		return nil, status.Error(codes.FailedPrecondition, "email address of this account is not verified")
+	}
+
+	if err := server.lockout.Succeed(ctx, credentials.Email); err != nil {
+		logger.FromContext(ctx).Error("Error resetting the login attempts", zap.Uint64("id", user.Id), zap.Error(err))
+	}
+
+	return toProto(user), nil
+}
+
+func (server *Server) failAttempt(ctx context.Context, email, ip string) {
+	if err := server.lockout.Fail(ctx, email, ip); err != nil {
+		logger.FromContext(ctx).Error("Error recording the failed attempt", zap.String("ip", ip), zap.Error(err))
+	}
+}
+
+// clientIP is the ip of the client, taken from the x-forwarded-for metadata of
+// the calls made by the trusted proxies, or the ip of the caller otherwise. It's
+// empty when a trusted proxy forwards no client, whose ip would lock out all
+// of its clients, so the per-ip lockout is skipped.
+func (server *Server) clientIP(ctx context.Context) string {
+	caller, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	remote, ok := caller.Addr.(*net.TCPAddr)
+	if !ok {
+		return ""
+	} else if !server.proxies.Contains(remote.IP) {
+		return remote.IP.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ip := server.proxies.ClientIP(strings.Join(md.Get("x-forwarded-for"), ",")); ip != nil {
+		return ip.String()
+	}
+
+	return ""
+}
+
+func toProto(user *models.User) *pb.User {
+	return &pb.User{
+		Id:            user.Id,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Email:         user.Email,
+		CreatedAt:     user.CreatedAt,
+		EmailVerified: user.EmailVerifiedAt != nil,
+	}
+}
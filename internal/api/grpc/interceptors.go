@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthService is the prefix of the methods of the grpc health service
+const healthService = "/grpc.health.v1.Health/"
+
+// requestIdInterceptor accepts the x-request-id of the caller or generates
+// one, echoes it in the header and stores the logger tagged with it in ctx,
+// so it's logged by the other interceptors and forwarded like on http.
+func (server *Server) requestIdInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := ""
+	if ids := md.Get(RequestIdMetadata); len(ids) != 0 {
+		id = ids[0]
+	}
+	if !logger.ValidRequestId(id) {
+		id = uuid.NewString()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIdMetadata, id))
+
+	ctx = logger.WithRequestId(ctx, id)
+	ctx = logger.NewContext(ctx, server.logger, zap.String("request_id", id), zap.String("method", info.FullMethod))
+
+	return handler(ctx, req)
+}
+
+func (server *Server) recoveryInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (response any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.FromContext(ctx).Error("panic while handling grpc call",
+				zap.Any("panic", recovered),
+				zap.ByteString("stack", debug.Stack()),
+			)
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func (server *Server) loggingInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+	response, err := handler(ctx, req)
+
+	fields := []zap.Field{
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("duration", time.Since(start)),
+	}
+
+	if err != nil {
+		logger.FromContext(ctx).Error("grpc call failed", append(fields, zap.Error(err))...)
+	} else {
+		logger.FromContext(ctx).Debug("grpc call handled", fields...)
+	}
+
+	return response, err
+}
+
+// authInterceptor rejects the calls without the bearer AuthToken in their
+// authorization metadata, except the ones of the health service which are
+// made by the probes.
+func (server *Server) authInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	if !server.authorized(ctx, info.FullMethod) {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid authorization token")
+	}
+
+	return handler(ctx, req)
+}
+
+// streamAuthInterceptor applies the rules of authInterceptor to the streams,
+// which are the reflection and the health watch.
+func (server *Server) streamAuthInterceptor(
+	srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	if !server.authorized(stream.Context(), info.FullMethod) {
+		return status.Error(codes.Unauthenticated, "missing or invalid authorization token")
+	}
+
+	return handler(srv, stream)
+}
+
+func (server *Server) authorized(ctx context.Context, method string) bool {
+	if strings.HasPrefix(method, healthService) {
+		return true
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, authorization := range md.Get("authorization") {
+		token, found := strings.CutPrefix(authorization, "Bearer ")
+		if found && subtle.ConstantTimeCompare([]byte(token), []byte(server.config.AuthToken)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deadlineInterceptor bounds the calls without a deadline by the default
+// timeout and rejects the ones whose deadline has already passed.
+func (server *Server) deadlineInterceptor(
+	ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	if _, ok := ctx.Deadline(); !ok && server.config.DefaultTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, server.config.DefaultTimeout)
+		defer cancel()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,23 @@
+package grpc
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	authClientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "user",
+		Subsystem: "auth_client",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the calls to the auth service by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	authClientFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "user",
+		Subsystem: "auth_client",
+		Name:      "failures_total",
+		Help:      "Failed calls to the auth service by method, rejected tokens are not failures.",
+	}, []string{"method"})
+)
@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/CafeKetab/user/internal/lockout"
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/hasher"
+	readiness "github.com/CafeKetab/user/pkg/health"
+	pb "github.com/CafeKetab/user/pkg/pb/user"
+	"github.com/CafeKetab/user/pkg/proxy"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+type Server struct {
+	pb.UnimplementedUserServiceServer
+
+	config     *Config
+	logger     *zap.Logger
+	repository repository.Repository
+	hasher     hasher.Hasher
+	lockout    lockout.Tracker
+	readiness  readiness.Health
+
+	// proxies are the parsed TrustedProxies of the config
+	proxies proxy.Trusted
+
+	// verificationRequired rejects the credentials of unverified emails, like
+	// the http login does
+	verificationRequired bool
+
+	server *grpc.Server
+	health *health.Server
+	done   chan struct{}
+}
+
+func NewServer(
+	cfg *Config, lg *zap.Logger, repo repository.Repository, hasher hasher.Hasher, lockout lockout.Tracker,
+	readiness readiness.Health, verificationRequired bool,
+) *Server {
+	server := &Server{
+		config: cfg, logger: lg, repository: repo, hasher: hasher, lockout: lockout,
+		readiness: readiness, verificationRequired: verificationRequired, done: make(chan struct{}),
+		proxies: proxy.Parse(lg, cfg.TrustedProxies),
+	}
+
+	// the first interceptor is the outermost one, the request id is set first so
+	// every log line carries it, then the panics of the others are recovered
+	server.server = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		server.requestIdInterceptor,
+		server.recoveryInterceptor,
+		server.loggingInterceptor,
+		server.authInterceptor,
+		server.deadlineInterceptor,
+	), grpc.StreamInterceptor(server.streamAuthInterceptor))
+
+	pb.RegisterUserServiceServer(server.server, server)
+
+	// the serving status follows the readiness checks, see syncHealth
+	server.health = health.NewServer()
+	server.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server.server, server.health)
+
+	if cfg.Reflection {
+		reflection.Register(server.server)
+	}
+
+	return server
+}
+
+// Serve blocks until the server is shut down, it returns nil afterwards
+func (server *Server) Serve() error {
+	addr := fmt.Sprintf(":%d", server.config.ListenPort)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		server.logger.Error("error listening for grpc server", zap.String("address", addr), zap.Error(err))
+		return err
+	}
+
+	go server.syncHealth()
+
+	if err := server.server.Serve(listener); err != nil {
+		server.logger.Error("error resolving grpc server", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown reports NOT_SERVING on the health service and waits for the calls
+// in flight until the deadline of ctx, the remaining ones are cancelled afterwards.
+func (server *Server) Shutdown(ctx context.Context) error {
+	close(server.done)
+	server.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// syncHealth reports the readiness checks on the health service every
+// HealthInterval, until the server is shut down.
+func (server *Server) syncHealth() {
+	ticker := time.NewTicker(server.config.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), server.config.HealthInterval)
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if server.readiness.Ready(ctx).Ready() {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		cancel()
+
+		server.setServingStatus(status)
+
+		select {
+		case <-server.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setServingStatus sets the status of the server and of the user service
+func (server *Server) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	server.health.SetServingStatus("", status)
+	server.health.SetServingStatus(pb.UserService_ServiceDesc.ServiceName, status)
+}
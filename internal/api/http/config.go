@@ -1,5 +1,57 @@
 package http
 
+import "time"
+
 type Config struct {
 	ListenPort int `koanf:"listen_port"`
+
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in front
+	// of the server, the client ip of their requests is read from ProxyHeader.
+	// The ip of the connection is the client ip when it is empty.
+	TrustedProxies []string `koanf:"trusted_proxies"`
+	ProxyHeader    string   `koanf:"proxy_header"`
+
+	// AdminToken guards the admin endpoints, they are disabled when it is empty
+	AdminToken string `koanf:"admin_token" redact:"true"`
+
+	Verification  *VerificationConfig  `koanf:"verification"`
+	PasswordReset *PasswordResetConfig `koanf:"password_reset"`
+	TwoFactor     *TwoFactorConfig     `koanf:"two_factor"`
+}
+
+type VerificationConfig struct {
+	// Required makes login reject the accounts with an unverified email
+	Required bool          `koanf:"required"`
+	TokenTTL time.Duration `koanf:"token_ttl"`
+
+	// URL is the link sent to the user, {token} is replaced with the token
+	URL string `koanf:"url"`
+
+	// SendTimeout bounds the sending of the email requested again, which is
+	// done in the background after the response.
+	SendTimeout time.Duration `koanf:"send_timeout"`
+}
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration `koanf:"token_ttl"`
+
+	// URL is the link sent to the user, {token} is replaced with the token
+	URL string `koanf:"url"`
+
+	// SendTimeout bounds the sending of the email, which is done in the
+	// background after the response.
+	SendTimeout time.Duration `koanf:"send_timeout"`
+}
+
+type TwoFactorConfig struct {
+	// Issuer is the account name shown in the authenticator apps
+	Issuer string `koanf:"issuer"`
+
+	// ChallengeTTL is how long the second step of the login can be completed
+	ChallengeTTL time.Duration `koanf:"challenge_ttl"`
+
+	// Skew is the number of 30 second steps of clock drift accepted in each direction
+	Skew int64 `koanf:"skew"`
+
+	RecoveryCodes int `koanf:"recovery_codes"`
 }
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// Error codes of the APIError, they are part of the api contract so clients
+// can rely on them and they must not be changed once released.
+const (
+	CodeInternal          = "internal_error"
+	CodeNotFound          = "not_found"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeBadRequest        = "bad_request"
+	CodeInvalidBody       = "invalid_body"
+	CodeInvalidEmail      = "invalid_email"
+	CodeInvalidId         = "invalid_id"
+	CodeInvalidPassword   = "invalid_password"
+	CodeEmptyUpdate       = "empty_update"
+	CodePasswordPolicy    = "password_policy_violation"
+	CodeEmailTaken        = "email_taken"
+	CodeUserNotFound      = "user_not_found"
+	CodeInvalidCredential = "invalid_credentials"
+	CodeWrongPassword     = "wrong_password"
+	CodeResetRequired     = "password_reset_required"
+	CodeEmailNotVerified  = "email_not_verified"
+	CodeTooManyAttempts   = "too_many_attempts"
+	CodeInvalidToken      = "invalid_token"
+	CodeInvalidTwoFactor  = "invalid_two_factor_code"
+	CodeTwoFactorEnabled  = "two_factor_already_enabled"
+	CodeTwoFactorDisabled = "two_factor_not_enabled"
+	CodeTwoFactorPending  = "two_factor_not_enrolled"
+	CodeUnauthenticated   = "unauthenticated"
+	CodeInvalidBearer     = "invalid_access_token"
+	CodeForbidden         = "forbidden"
+	CodeAuthUnavailable   = "auth_unavailable"
+)
+
+// APIError is the body of every error response of the server
+type APIError struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestId string `json:"request_id"`
+}
+
+func NewAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// WithDetails attaches machine-readable details, e.g. the password policy violations
+func (err *APIError) WithDetails(details any) *APIError {
+	err.Details = details
+	return err
+}
+
+func (err *APIError) Error() string {
+	return err.Code + ": " + err.Message
+}
+
+// errorHandler is the central fiber error handler, handlers return either an
+// *APIError or a domain error which is mapped to the corresponding APIError.
+func (handler *Server) errorHandler(c *fiber.Ctx, err error) error {
+	response := *handler.toAPIError(c.UserContext(), err)
+	response.RequestId = c.GetRespHeader(fiber.HeaderXRequestID, c.Get(fiber.HeaderXRequestID))
+
+	return c.Status(response.Status).JSON(&response)
+}
+
+func (handler *Server) toAPIError(ctx context.Context, err error) *APIError {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError
+	}
+
+	var fiberError *fiber.Error
+	if errors.As(err, &fiberError) {
+		switch {
+		case fiberError.Code == http.StatusNotFound:
+			return NewAPIError(fiberError.Code, CodeNotFound, fiberError.Message)
+		case fiberError.Code == http.StatusMethodNotAllowed:
+			return NewAPIError(fiberError.Code, CodeMethodNotAllowed, fiberError.Message)
+		case fiberError.Code < http.StatusInternalServerError:
+			return NewAPIError(fiberError.Code, CodeBadRequest, fiberError.Message)
+		}
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		errString := "User doesn't exist"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusNotFound, CodeUserNotFound, errString)
+	case errors.Is(err, repository.ErrEmailTaken):
+		errString := "User with given email already exists"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusConflict, CodeEmailTaken, errString)
+	case errors.Is(err, repository.ErrTokenNotFound):
+		errString := "Invalid or expired token"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidToken, errString)
+	}
+
+	errString := "Internal server error"
+	logger.FromContext(ctx).Error(errString, zap.Error(err))
+	return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+}
@@ -1,54 +1,74 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/CafeKetab/user/internal/models"
-	"github.com/CafeKetab/user/pkg/rdbms"
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/logger"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 )
 
 func (handler *Server) register(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
 	request := struct{ Email, Password string }{}
 	if err := c.BodyParser(&request); err != nil {
 		errString := "Error parsing request body"
-		handler.logger.Error(errString, zap.Any("request", request), zap.Error(err))
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
 	}
 
-	user, err := handler.repository.FindUserByEmail(ctx, request.Email)
-	if err != nil && err.Error() != rdbms.ErrReadNotFound {
-		errString := "Error while retrieving data from database"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
-	} else if err == nil || (user != nil && user.Id != 0) {
-		errString := "User with given email already exists"
-		handler.logger.Error(errString, zap.String("email", request.Email))
-		return c.Status(http.StatusBadRequest).SendString(errString)
+	if violations := handler.policy.Validate(request.Password, request.Email); len(violations) != 0 {
+		errString := "Password does not satisfy the password policy"
+		logger.FromContext(ctx).Error(errString, zap.Any("violations", violations))
+		return NewAPIError(http.StatusBadRequest, CodePasswordPolicy, errString).WithDetails(violations)
 	}
 
-	user = &models.User{Email: request.Email, Password: request.Password}
-	if err := handler.repository.CreateUser(ctx, user); err != nil {
-		errString := "Error happened while creating the user"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+	password, err := handler.hasher.Hash(request.Password)
+	if err != nil {
+		errString := "Error happened while hashing the password"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	user := &models.User{Email: request.Email, Password: password}
+	err = handler.repository.WithTx(ctx, func(tx repository.Repository) error {
+		if _, err := tx.FindUserByEmail(ctx, request.Email); err == nil {
+			return repository.ErrEmailTaken
+		} else if !errors.Is(err, repository.ErrUserNotFound) {
+			return fmt.Errorf("error while retrieving data from database: %w", err)
+		}
+
+		return tx.CreateUser(ctx, user)
+	})
+	if err != nil {
+		return fmt.Errorf("error happened while creating the user: %w", err)
 	}
 
 	if user.Id == 0 {
 		errString := "Error invalid user id created"
-		handler.logger.Error(errString, zap.Any("user", user))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Any("user", user))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	registrationsTotal.Inc()
+
+	if err := handler.sendVerificationEmail(ctx, user); err != nil {
+		logger.FromContext(ctx).Error("Error sending the verification email", zap.Uint64("id", user.Id), zap.Error(err))
 	}
 
 	token, err := handler.auth.GenerateToken(ctx, user.Id)
 	if err != nil {
 		errString := "Error creating JWT token for user"
-		handler.logger.Error(errString, zap.Any("user", user), zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Any("user", user), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
 	response := map[string]string{"Token": token}
@@ -56,237 +76,319 @@ func (handler *Server) register(c *fiber.Ctx) error {
 }
 
 func (handler *Server) login(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
 	request := struct{ Email, Password string }{}
 	if err := c.BodyParser(&request); err != nil {
 		errString := "Error parsing request body"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
 	}
 
-	user, err := handler.repository.FindUserByEmailAndPassword(ctx, request.Email, request.Password)
+	wait, err := handler.lockout.Check(ctx, request.Email, handler.clientIP(c))
 	if err != nil {
-		errString := "Wrong email or password has been given"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		errString := "Error while checking the login attempts"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	} else if wait > 0 {
+		errString := "Too many failed login attempts, try again later"
+		logger.FromContext(ctx).Error(errString, zap.String("ip", handler.clientIP(c)), zap.Duration("wait", wait))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		loginsTotal.WithLabelValues(LoginLocked).Inc()
+		return NewAPIError(http.StatusTooManyRequests, CodeTooManyAttempts, errString)
+	}
+
+	user, err := handler.repository.FindUserByEmail(ctx, request.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			// hashing anyway keeps the registered emails from being told apart by the response time
+			handler.hasher.VerifyDummy(request.Password)
+			handler.failLogin(ctx, request.Email, handler.clientIP(c))
+
+			errString := "Wrong email or password has been given"
+			logger.FromContext(ctx).Error(errString, zap.Error(err))
+			return NewAPIError(http.StatusBadRequest, CodeInvalidCredential, errString)
+		}
+
+		errString := "Error while retrieving data from database"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
 	if user == nil {
 		errString := "Error invalid user returned"
-		handler.logger.Error(errString, zap.Any("request", request))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, logger.Email("email", request.Email))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if ok, err := handler.hasher.Verify(request.Password, user.Password); err != nil || !ok {
+		handler.failLogin(ctx, request.Email, handler.clientIP(c))
+
+		errString := "Wrong email or password has been given"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id), zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidCredential, errString)
+	}
+
+	// checked after the password, so the flag of an account is not disclosed to anyone
+	if user.PasswordResetRequired {
+		errString := "Password reset is required for this account"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id))
+		return NewAPIError(http.StatusForbidden, CodeResetRequired, errString)
+	}
+
+	if handler.config.Verification.Required && user.EmailVerifiedAt == nil {
+		errString := "Email address of the account is not verified"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id))
+		return NewAPIError(http.StatusForbidden, CodeEmailNotVerified, errString)
+	}
+
+	// upgrade hashes created by an older algorithm or with outdated parameters
+	if handler.hasher.NeedsRehash(user.Password) {
+		handler.rehashPassword(ctx, user.Id, request.Password)
+	}
+
+	// the token is issued by loginTwoFactor once the second factor is verified
+	if user.TwoFactorEnabled {
+		challenge, err := handler.createLoginChallenge(ctx, user)
+		if err != nil {
+			errString := "Error creating the two-factor challenge"
+			logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id), zap.Error(err))
+			return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+		}
+
+		response := map[string]any{"TwoFactorRequired": true, "Challenge": challenge}
+		return c.Status(http.StatusOK).JSON(&response)
+	}
+
+	if err := handler.lockout.Succeed(ctx, request.Email); err != nil {
+		logger.FromContext(ctx).Error("Error resetting the login attempts", zap.Uint64("id", user.Id), zap.Error(err))
 	}
 
 	// request token
 	token, err := handler.auth.GenerateToken(ctx, user.Id)
 	if err != nil {
 		errString := "Error creating JWT token for user"
-		handler.logger.Error(errString, zap.Any("user", user), zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Any("user", user), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
+	loginsTotal.WithLabelValues(LoginSuccess).Inc()
+
 	response := map[string]string{"Token": token}
 	return c.Status(http.StatusOK).JSON(&response)
 }
 
+// unlock lifts the lockout of the given email
+func (handler *Server) unlock(c *fiber.Ctx) error {
+	email := c.Params("email")
+	if len(email) == 0 {
+		errString := "Error invalid email has been given"
+		logger.FromContext(c.UserContext()).Error(errString)
+		return NewAPIError(http.StatusBadRequest, CodeInvalidEmail, errString)
+	}
+
+	if err := handler.lockout.Unlock(c.UserContext(), email); err != nil {
+		errString := "Error while unlocking the account"
+		logger.FromContext(c.UserContext()).Error(errString, zap.String("email", email), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	logger.FromContext(c.UserContext()).Info("Account has been unlocked", zap.String("email", email))
+	return c.SendStatus(http.StatusNoContent)
+}
+
 // get user by id
 func (handler *Server) user(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	idString := c.Params("id")
 
 	id, err := strconv.ParseUint(idString, 10, 64)
 	if err != nil {
 		errString := "Error invalid id for the user"
-		handler.logger.Error(errString, zap.String("id", idString))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.String("id", idString))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidId, errString)
 	} else if id <= 0 {
 		errString := "Error invalid id has been given"
-		handler.logger.Error(errString)
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString)
+		return NewAPIError(http.StatusBadRequest, CodeInvalidId, errString)
 	}
 
 	user, err := handler.repository.FindUserById(ctx, id)
 	if err != nil {
-		if err.Error() == rdbms.ErrReadNotFound {
-			errString := "User with given id doesn't exists"
-			return c.Status(http.StatusBadRequest).SendString(errString)
-		}
-
-		errString := "Error while retrieving the user"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		return fmt.Errorf("error while retrieving the user: %w", err)
 	}
 
 	if user == nil {
 		errString := "Error invalid user returned"
-		handler.logger.Error(errString, zap.Uint64("id", id))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
-	return c.Status(http.StatusOK).JSON(&user)
+	return c.Status(http.StatusOK).JSON(user.Marshall(true))
 }
 
 // get user of the header
 func (handler *Server) me(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
-	id, ok := c.Locals("id").(uint64)
+	principal, ok := c.Locals(principalKey).(*models.Principal)
 	if !ok {
-		errString := "Error invalid id for the user"
-		handler.logger.Error(errString, zap.Any("id", c.Locals("id")))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
-	} else if id <= 0 {
-		errString := "Error invalid id has been given"
-		handler.logger.Error(errString)
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		errString := "Error invalid principal for the request"
+		logger.FromContext(ctx).Error(errString, zap.Any("principal", c.Locals(principalKey)))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
+	id := principal.Id
 
 	user, err := handler.repository.FindUserById(ctx, id)
 	if err != nil {
-		if err.Error() == rdbms.ErrReadNotFound {
-			errString := "Error finding user of the request"
-			return c.Status(http.StatusInternalServerError).SendString(errString)
-		}
-
-		errString := "Error while retrieving the user"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		return fmt.Errorf("error while retrieving the user: %w", err)
 	}
 
 	if user == nil {
 		errString := "Error invalid user returned"
-		handler.logger.Error(errString, zap.Any("id", id))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Any("id", id))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
-	return c.Status(http.StatusOK).JSON(&user)
+	return c.Status(http.StatusOK).JSON(user.Marshall(false))
 }
 
 func (handler *Server) updateInformation(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
-	id, ok := c.Locals("id").(uint64)
+	principal, ok := c.Locals(principalKey).(*models.Principal)
 	if !ok {
-		errString := "Error invalid id for the user"
-		handler.logger.Error(errString, zap.Any("id", c.Locals("id")))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
-	} else if id <= 0 {
-		errString := "Error invalid id has been given"
-		handler.logger.Error(errString)
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		errString := "Error invalid principal for the request"
+		logger.FromContext(ctx).Error(errString, zap.Any("principal", c.Locals(principalKey)))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
+	id := principal.Id
 
 	request := struct{ FirstName, LastName string }{}
 	if err := c.BodyParser(&request); err != nil {
 		errString := "Error parsing request body"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
 	}
 
 	if len(request.FirstName) == 0 && len(request.LastName) == 0 {
 		errString := "An empty request body has been given"
-		handler.logger.Error(errString)
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString)
+		return NewAPIError(http.StatusBadRequest, CodeEmptyUpdate, errString)
 	}
 
-	user, err := handler.repository.FindUserById(ctx, id)
-	if err != nil {
-		if err.Error() == rdbms.ErrReadNotFound {
-			errString := "Error finding user of the request"
-			return c.Status(http.StatusInternalServerError).SendString(errString)
+	// the user is read and written in one transaction so concurrent updates of
+	// the other name are not lost
+	err := handler.repository.WithTx(ctx, func(tx repository.Repository) error {
+		user, err := tx.FindUserById(ctx, id)
+		if err != nil {
+			return fmt.Errorf("error while retrieving the user: %w", err)
 		}
 
-		errString := "Error while retrieving the user"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
-	}
-
-	if user == nil {
-		errString := "Error invalid user returned"
-		handler.logger.Error(errString, zap.Any("id", id))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
-	}
+		if len(request.FirstName) != 0 {
+			user.FirstName = request.FirstName
+		}
 
-	if len(request.FirstName) != 0 {
-		user.FirstName = request.FirstName
-	}
+		if len(request.LastName) != 0 {
+			user.LastName = request.LastName
+		}
 
-	if len(request.LastName) != 0 {
-		user.LastName = request.LastName
-	}
+		if err := tx.UpdateUser(ctx, user); err != nil {
+			return fmt.Errorf("error while updating the user: %w", err)
+		}
 
-	if err := handler.repository.UpdateUser(ctx, user); err != nil {
-		errString := "Error while updating the user"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.SendStatus(http.StatusOK)
 }
 
 func (handler *Server) updatePassword(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 
-	id, ok := c.Locals("id").(uint64)
+	principal, ok := c.Locals(principalKey).(*models.Principal)
 	if !ok {
-		errString := "Error invalid id for the user"
-		handler.logger.Error(errString, zap.Any("id", c.Locals("id")))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
-	} else if id <= 0 {
-		errString := "Error invalid id has been given"
-		handler.logger.Error(errString)
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		errString := "Error invalid principal for the request"
+		logger.FromContext(ctx).Error(errString, zap.Any("principal", c.Locals(principalKey)))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
+	id := principal.Id
 
 	request := struct{ OldPassword, NewPassword string }{}
 	if err := c.BodyParser(&request); err != nil {
 		errString := "Error parsing request body"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
 	}
 
 	if len(request.OldPassword) == 0 {
 		errString := "Invalid old password has been given"
-		handler.logger.Error(errString)
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString)
+		return NewAPIError(http.StatusBadRequest, CodeInvalidPassword, errString)
 	} else if len(request.NewPassword) == 0 {
 		errString := "Invalid password has been given"
-		handler.logger.Error(errString)
-		return c.Status(http.StatusBadRequest).SendString(errString)
+		logger.FromContext(ctx).Error(errString)
+		return NewAPIError(http.StatusBadRequest, CodeInvalidPassword, errString)
 	}
 
 	user, err := handler.repository.FindUserById(ctx, id)
 	if err != nil {
-		if err.Error() == rdbms.ErrReadNotFound {
-			errString := "Error finding user of the request"
-			return c.Status(http.StatusInternalServerError).SendString(errString)
-		}
-
-		errString := "Error while retrieving the user"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		return fmt.Errorf("error while retrieving the user: %w", err)
 	}
 
 	if user == nil {
 		errString := "Error invalid user returned"
-		handler.logger.Error(errString, zap.Any("id", id))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Any("id", id))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
-	if request.OldPassword != user.Password {
+	if ok, err := handler.hasher.Verify(request.OldPassword, user.Password); err != nil || !ok {
 		errString := "Error wrong old password"
-		handler.logger.Error(errString, zap.Uint64("id", id), zap.Any("request", request))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
-	} else {
-		user.Password = request.NewPassword
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeWrongPassword, errString)
+	}
+
+	if violations := handler.policy.Validate(request.NewPassword, user.Email); len(violations) != 0 {
+		errString := "Password does not satisfy the password policy"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Any("violations", violations))
+		return NewAPIError(http.StatusBadRequest, CodePasswordPolicy, errString).WithDetails(violations)
+	}
+
+	password, err := handler.hasher.Hash(request.NewPassword)
+	if err != nil {
+		errString := "Error happened while hashing the password"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
-	if err := handler.repository.UpdateUser(ctx, user); err != nil {
+	if err := handler.repository.UpdatePassword(ctx, user.Id, password); err != nil {
 		errString := "Error while updating the user"
-		handler.logger.Error(errString, zap.Error(err))
-		return c.Status(http.StatusInternalServerError).SendString(errString)
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
 	}
 
 	return c.SendStatus(http.StatusOK)
 }
+
+func (handler *Server) rehashPassword(ctx context.Context, id uint64, plain string) {
+	password, err := handler.hasher.Hash(plain)
+	if err != nil {
+		logger.FromContext(ctx).Error("Error rehashing the password", zap.Uint64("id", id), zap.Error(err))
+		return
+	}
+
+	if err := handler.repository.UpdatePassword(ctx, id, password); err != nil {
+		logger.FromContext(ctx).Error("Error storing the rehashed password", zap.Uint64("id", id), zap.Error(err))
+	}
+}
+
+func (handler *Server) failLogin(ctx context.Context, email, ip string) {
+	loginsTotal.WithLabelValues(LoginFailure).Inc()
+
+	if err := handler.lockout.Fail(ctx, email, ip); err != nil {
+		logger.FromContext(ctx).Error("Error recording the failed login", zap.String("ip", ip), zap.Error(err))
+	}
+}
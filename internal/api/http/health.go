@@ -0,0 +1,24 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/CafeKetab/user/pkg/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+// healthz reports the process is alive, it checks no dependency so a broken
+// database does not get the pods restarted
+func (handler *Server) healthz(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(map[string]string{"status": health.StatusUp})
+}
+
+// readyz reports whether the service can take traffic along with every check
+func (handler *Server) readyz(c *fiber.Ctx) error {
+	report := handler.health.Ready(c.UserContext())
+	if !report.Ready() {
+		return c.Status(http.StatusServiceUnavailable).JSON(report)
+	}
+
+	return c.Status(http.StatusOK).JSON(report)
+}
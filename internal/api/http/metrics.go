@@ -0,0 +1,93 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// Results of the logins counter
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginLocked  = "locked"
+)
+
+// unmatchedRoute is the route label of the requests no route has matched,
+// their raw paths would make the cardinality of the labels unbounded.
+const unmatchedRoute = "unmatched"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "user",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Handled requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "user",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the requests by method, route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	registrationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "user",
+		Name:      "registrations_total",
+		Help:      "Users who have registered successfully.",
+	})
+
+	loginsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "user",
+		Name:      "logins_total",
+		Help:      "Login attempts by result, either success, failure or locked.",
+	}, []string{"result"})
+)
+
+// routeKey is the key of the route label of the request in the locals
+const routeKey = "route"
+
+// metrics records the requests by their route template, e.g. /v1/:id<int>.
+// The error is passed to the error handler here so the final status is known,
+// the same way the logger middleware of fiber does.
+func (middleware *Server) metrics(c *fiber.Ctx) error {
+	start := time.Now()
+
+	route := ""
+	if err := c.Next(); err != nil {
+		if handlerErr := c.App().Config().ErrorHandler(c, err); handlerErr != nil {
+			logger.FromContext(c.UserContext()).Error("Error handling the error of the request", zap.Error(handlerErr))
+			c.Status(http.StatusInternalServerError)
+		}
+
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) && (fiberErr.Code == http.StatusNotFound || fiberErr.Code == http.StatusMethodNotAllowed) {
+			route = unmatchedRoute
+		}
+	}
+
+	if len(route) == 0 {
+		route = c.Route().Path
+	}
+	c.Locals(routeKey, route)
+
+	observeRequest(c.Method(), route, c.Response().StatusCode(), start)
+	return nil
+}
+
+func observeRequest(method, route string, status int, start time.Time) {
+	// the method is backed by the request buffer of fasthttp which is reused
+	labels := []string{utils.CopyString(method), route, strconv.Itoa(status)}
+	requestsTotal.WithLabelValues(labels...).Inc()
+	requestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
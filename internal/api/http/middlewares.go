@@ -1,29 +1,81 @@
 package http
 
 import (
+	"crypto/subtle"
 	"errors"
-	"strconv"
+	"net/http"
+	"strings"
 
+	"github.com/CafeKetab/user/internal/auth"
+	"github.com/CafeKetab/user/pkg/logger"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"go.uber.org/zap"
 )
 
-func (middleware *Server) fetchUserId(c *fiber.Ctx) error {
-	header := c.Request().Header.Peek("X-User-Id")
+// principalKey is the key of the authenticated *models.Principal in the locals
+const principalKey = "principal"
 
-	id, err := strconv.ParseUint(string(header), 10, 64)
-	if err != nil {
-		middleware.logger.Error("invalid id header", zap.ByteString("header", header), zap.Error(err))
-		return err
+// requestId accepts the X-Request-Id of the client or generates one, echoes
+// it in the response and stores the logger tagged with it in the user context.
+func (middleware *Server) requestId(c *fiber.Ctx) error {
+	id := c.Get(fiber.HeaderXRequestID)
+	if !logger.ValidRequestId(id) {
+		id = utils.UUIDv4()
+	} else {
+		id = utils.CopyString(id)
 	}
+	c.Set(fiber.HeaderXRequestID, id)
+
+	ctx := logger.WithRequestId(c.UserContext(), id)
+	ctx = logger.NewContext(ctx, middleware.logger,
+		zap.String("request_id", id),
+		zap.String("route", utils.CopyString(c.Method())+" "+utils.CopyString(c.Path())),
+	)
+	c.SetUserContext(ctx)
+
+	return c.Next()
+}
 
-	if id == 0 {
-		errString := "Invalid id value"
-		middleware.logger.Error(errString, zap.ByteString("header", header))
-		return errors.New(errString)
+// authenticate validates the bearer token of the request and stores its principal in the locals
+func (middleware *Server) authenticate(c *fiber.Ctx) error {
+	scheme, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || len(strings.TrimSpace(token)) == 0 {
+		errString := "Bearer token is missing"
+		logger.FromContext(c.UserContext()).Error(errString, zap.String("path", c.Path()))
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return NewAPIError(http.StatusUnauthorized, CodeUnauthenticated, errString)
 	}
 
-	c.Locals("id", id)
+	principal, err := middleware.authenticator.Authenticate(c.UserContext(), strings.TrimSpace(token))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			errString := "Bearer token is invalid or expired"
+			logger.FromContext(c.UserContext()).Error(errString, zap.String("path", c.Path()))
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return NewAPIError(http.StatusUnauthorized, CodeInvalidBearer, errString)
+		}
+
+		errString := "Error while validating the bearer token"
+		logger.FromContext(c.UserContext()).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusServiceUnavailable, CodeAuthUnavailable, errString)
+	}
+
+	c.Locals(principalKey, principal)
+	c.SetUserContext(logger.With(c.UserContext(), zap.Uint64("user_id", principal.Id)))
+
+	return c.Next()
+}
+
+func (middleware *Server) requireAdmin(c *fiber.Ctx) error {
+	token := c.Get("X-Admin-Token")
+
+	expected := middleware.config.AdminToken
+	if len(expected) == 0 || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		errString := "Admin token is missing or invalid"
+		logger.FromContext(c.UserContext()).Error(errString, zap.String("path", c.Path()), zap.String("ip", middleware.clientIP(c)))
+		return NewAPIError(http.StatusForbidden, CodeForbidden, errString)
+	}
 
 	return c.Next()
 }
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/CafeKetab/user/internal/auth"
+	"github.com/CafeKetab/user/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// stubAuthenticator accepts the "valid" token and fails the others with err
+type stubAuthenticator struct {
+	err error
+}
+
+func (a *stubAuthenticator) Authenticate(_ context.Context, token string) (*models.Principal, error) {
+	if token == "valid" {
+		return &models.Principal{Id: 42}, nil
+	}
+
+	return nil, a.err
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		err           error
+		status        int
+		code          string
+	}{
+		{name: "valid token", authorization: "Bearer valid", status: http.StatusOK},
+		{name: "lowercase scheme", authorization: "bearer valid", status: http.StatusOK},
+		{name: "missing token", status: http.StatusUnauthorized, code: CodeUnauthenticated},
+		{name: "empty token", authorization: "Bearer  ", status: http.StatusUnauthorized, code: CodeUnauthenticated},
+		{name: "other scheme", authorization: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized, code: CodeUnauthenticated},
+		{name: "invalid token", authorization: "Bearer invalid", err: auth.ErrInvalidToken, status: http.StatusUnauthorized, code: CodeInvalidBearer},
+		{name: "auth unavailable", authorization: "Bearer other", err: errors.New("connection refused"), status: http.StatusServiceUnavailable, code: CodeAuthUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &Server{logger: zap.NewNop(), authenticator: &stubAuthenticator{err: test.err}}
+
+			app := fiber.New(fiber.Config{ErrorHandler: server.errorHandler})
+			app.Get("/", server.authenticate, func(c *fiber.Ctx) error {
+				principal := c.Locals(principalKey).(*models.Principal)
+				return c.SendString(strconv.FormatUint(principal.Id, 10))
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(test.authorization) != 0 {
+				request.Header.Set(fiber.HeaderAuthorization, test.authorization)
+			}
+
+			response, err := app.Test(request)
+			if err != nil {
+				t.Fatalf("sending request: %v", err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, response.StatusCode)
+			}
+
+			if test.status == http.StatusOK {
+				return
+			}
+
+			body := APIError{}
+			if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+
+			if body.Code != test.code {
+				t.Errorf("expected code %s, got %s", test.code, body.Code)
+			}
+
+			if test.status == http.StatusUnauthorized && len(response.Header.Get(fiber.HeaderWWWAuthenticate)) == 0 {
+				t.Errorf("expected the %s header", fiber.HeaderWWWAuthenticate)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CafeKetab/user/internal/models"
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/mailer"
+	"github.com/CafeKetab/user/pkg/token"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// forgotPassword emails a reset link to the account, the response is the same
+// whether an account with the given email exists or not.
+func (handler *Server) forgotPassword(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	request := struct{ Email string }{}
+	if err := c.BodyParser(&request); err != nil {
+		errString := "Error parsing request body"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
+	}
+
+	if len(request.Email) == 0 {
+		errString := "Invalid email has been given"
+		logger.FromContext(ctx).Error(errString)
+		return NewAPIError(http.StatusBadRequest, CodeInvalidEmail, errString)
+	}
+
+	user, err := handler.repository.FindUserByEmail(ctx, request.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return c.SendStatus(http.StatusAccepted)
+		}
+
+		errString := "Error while retrieving data from database"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	// the email is sent in the background so the response time does not
+	// reveal the existence of the account either, the shutdown waits for it
+	lg := logger.FromContext(ctx)
+	handler.manager.Go("password reset email", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), handler.config.PasswordReset.SendTimeout)
+		defer cancel()
+
+		// the failure is only logged, returning it would stop the server
+		if err := handler.sendPasswordResetEmail(ctx, user); err != nil {
+			lg.Error("Error sending the password reset email", zap.Uint64("id", user.Id), zap.Error(err))
+		}
+		return nil
+	})
+
+	return c.SendStatus(http.StatusAccepted)
+}
+
+func (handler *Server) resetPassword(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	request := struct{ Token, Password string }{}
+	if err := c.BodyParser(&request); err != nil {
+		errString := "Error parsing request body"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
+	}
+
+	hash := token.Hash(request.Token)
+
+	reset, err := handler.repository.FindToken(ctx, hash, models.TokenPurposePasswordReset)
+	if err != nil {
+		return fmt.Errorf("error while retrieving the password reset token: %w", err)
+	}
+
+	user, err := handler.repository.FindUserById(ctx, reset.UserId)
+	if err != nil {
+		errString := "Error while retrieving the user"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", reset.UserId), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if violations := handler.policy.Validate(request.Password, user.Email); len(violations) != 0 {
+		errString := "Password does not satisfy the password policy"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id), zap.Any("violations", violations))
+		return NewAPIError(http.StatusBadRequest, CodePasswordPolicy, errString).WithDetails(violations)
+	}
+
+	password, err := handler.hasher.Hash(request.Password)
+	if err != nil {
+		errString := "Error happened while hashing the password"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	// the token is consumed along with the password update, so a failed update
+	// leaves it usable, and consuming is atomic so only one of the concurrent
+	// requests wins it
+	err = handler.repository.WithTx(ctx, func(tx repository.Repository) error {
+		if _, err := tx.ConsumeToken(ctx, hash, models.TokenPurposePasswordReset); err != nil {
+			return fmt.Errorf("error while retrieving the password reset token: %w", err)
+		}
+
+		if err := tx.UpdatePassword(ctx, user.Id, password); err != nil {
+			return fmt.Errorf("error while updating the user: %w", err)
+		}
+
+		if err := tx.DeleteTokens(ctx, user.Id, models.TokenPurposePasswordReset); err != nil {
+			return fmt.Errorf("error while deleting the password reset tokens: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := handler.lockout.Unlock(ctx, user.Email); err != nil {
+		logger.FromContext(ctx).Error("Error unlocking the account", zap.Uint64("id", user.Id), zap.Error(err))
+	}
+
+	return c.SendStatus(http.StatusOK)
+}
+
+func (handler *Server) sendPasswordResetEmail(ctx context.Context, user *models.User) error {
+	plain, hash, err := token.Generate()
+	if err != nil {
+		return err
+	}
+
+	reset := &models.Token{
+		UserId:    user.Id,
+		Hash:      hash,
+		Purpose:   models.TokenPurposePasswordReset,
+		ExpiresAt: time.Now().Add(handler.config.PasswordReset.TokenTTL),
+	}
+
+	if err := handler.repository.CreateToken(ctx, reset); err != nil {
+		return err
+	}
+
+	link := strings.ReplaceAll(handler.config.PasswordReset.URL, "{token}", plain)
+	message := &mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your CafeKetab password",
+		Body: fmt.Sprintf(
+			"A password reset has been requested for your account, open the link below to choose a new password. "+
+				"It expires in %s, ignore this email if you did not request it.\n\n%s",
+			handler.config.PasswordReset.TokenTTL, link,
+		),
+	}
+
+	return handler.mailer.Send(ctx, message)
+}
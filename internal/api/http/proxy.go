@@ -0,0 +1,20 @@
+package http
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// clientIP is the ip of the client, taken from the ProxyHeader of the requests
+// sent by the trusted proxies, or the ip of the connection otherwise.
+func (handler *Server) clientIP(c *fiber.Ctx) string {
+	remote := c.Context().RemoteIP()
+	if !handler.proxies.Contains(remote) {
+		return remote.String()
+	}
+
+	if ip := handler.proxies.ClientIP(c.Get(handler.config.ProxyHeader)); ip != nil {
+		return ip.String()
+	}
+
+	return remote.String()
+}
@@ -1,11 +1,21 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	"github.com/CafeKetab/user/internal/api/grpc"
+	"github.com/CafeKetab/user/internal/auth"
+	"github.com/CafeKetab/user/internal/lockout"
+	"github.com/CafeKetab/user/internal/policy"
 	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/hasher"
+	"github.com/CafeKetab/user/pkg/health"
+	"github.com/CafeKetab/user/pkg/lifecycle"
+	"github.com/CafeKetab/user/pkg/mailer"
+	"github.com/CafeKetab/user/pkg/proxy"
+	"github.com/CafeKetab/user/pkg/secretbox"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 )
@@ -15,28 +25,82 @@ type Server struct {
 	logger     *zap.Logger
 	repository repository.Repository
 	auth       grpc.AuthClient
-	app        *fiber.App
+	hasher     hasher.Hasher
+	policy     policy.PasswordPolicy
+	lockout    lockout.Tracker
+	mailer     mailer.Mailer
+	secretbox  secretbox.SecretBox
+
+	authenticator auth.Authenticator
+	health        health.Health
+	manager       lifecycle.Manager
+
+	// proxies are the parsed TrustedProxies of the config
+	proxies proxy.Trusted
+
+	app *fiber.App
 }
 
-func New(cfg *Config, log *zap.Logger, repo repository.Repository, auth grpc.AuthClient) *Server {
-	server := &Server{config: cfg, logger: log, repository: repo, auth: auth}
+func New(
+	cfg *Config, log *zap.Logger, repo repository.Repository, authClient grpc.AuthClient,
+	authenticator auth.Authenticator, hasher hasher.Hasher, policy policy.PasswordPolicy,
+	lockout lockout.Tracker, mailer mailer.Mailer, secretbox secretbox.SecretBox, health health.Health,
+	manager lifecycle.Manager,
+) *Server {
+	server := &Server{
+		config: cfg, logger: log, repository: repo, auth: authClient, authenticator: authenticator,
+		hasher: hasher, policy: policy, lockout: lockout, mailer: mailer, secretbox: secretbox,
+		health: health, manager: manager, proxies: proxy.Parse(log, cfg.TrustedProxies),
+	}
+
+	server.app = fiber.New(fiber.Config{
+		JSONEncoder:  json.Marshal,
+		JSONDecoder:  json.Unmarshal,
+		ErrorHandler: server.errorHandler,
+	})
+
+	server.app.Use(server.requestId)
+	server.app.Use(server.tracing)
+	server.app.Use(server.metrics)
 
-	server.app = fiber.New(fiber.Config{JSONEncoder: json.Marshal, JSONDecoder: json.Unmarshal})
+	server.app.Get("/healthz", server.healthz)
+	server.app.Get("/readyz", server.readyz)
 
 	v1 := server.app.Group("/v1")
 	v1.Post("/register", server.register)
 	v1.Post("/login", server.login)
-	// v1.Get("/:id<uint64>", server.fetchUserId, server.user)
-	// v1.Get("/me", server.fetchUserId, server.me)
-	// v1.Post("/update-information", server.fetchUserId, server.updateInformation)
-	// v1.Post("/update-password", server.fetchUserId, server.updatePassword)
+	v1.Post("/login/two-factor", server.loginTwoFactor)
+	v1.Post("/verification/request", server.requestVerification)
+	v1.Post("/verification/confirm", server.confirmVerification)
+	v1.Post("/password/forgot", server.forgotPassword)
+	v1.Post("/password/reset", server.resetPassword)
+	v1.Get("/me", server.authenticate, server.me)
+	v1.Get("/:id<int>", server.authenticate, server.user)
+	v1.Post("/update-information", server.authenticate, server.updateInformation)
+	v1.Post("/update-password", server.authenticate, server.updatePassword)
+
+	v1.Post("/two-factor/enroll", server.authenticate, server.enrollTwoFactor)
+	v1.Post("/two-factor/confirm", server.authenticate, server.confirmTwoFactor)
+	v1.Post("/two-factor/disable", server.authenticate, server.disableTwoFactor)
+
+	v1.Delete("/admin/lockouts/:email", server.requireAdmin, server.unlock)
 
 	return server
 }
 
-func (server *Server) Serve() {
+// Serve blocks until the server is shut down, it returns nil afterwards
+func (server *Server) Serve() error {
 	addr := fmt.Sprintf(":%d", server.config.ListenPort)
 	if err := server.app.Listen(addr); err != nil {
-		server.logger.Fatal("error resolving server", zap.Error(err))
+		server.logger.Error("error resolving server", zap.Error(err))
+		return err
 	}
+
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for the requests in flight
+// until the deadline of ctx, the remaining connections are closed afterwards.
+func (server *Server) Shutdown(ctx context.Context) error {
+	return server.app.ShutdownWithContext(ctx)
 }
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/CafeKetab/user/internal/api/http")
+
+// headerCarrier adapts the request headers to the propagators of otel
+type headerCarrier struct{ c *fiber.Ctx }
+
+func (carrier headerCarrier) Get(key string) string { return carrier.c.Get(key) }
+
+func (carrier headerCarrier) Set(key, value string) { carrier.c.Request().Header.Set(key, value) }
+
+func (carrier headerCarrier) Keys() []string {
+	keys := make([]string, 0)
+	carrier.c.Request().Header.VisitAll(func(key, _ []byte) { keys = append(keys, string(key)) })
+	return keys
+}
+
+// tracing continues the trace of the W3C traceparent header, or starts a new
+// one, and stores the span in the user context the handlers pass down. It's
+// the outermost middleware so the status set by the error handler is known.
+func (middleware *Server) tracing(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+
+	method := utils.CopyString(c.Method())
+	ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		attribute.String("http.method", method),
+		attribute.String("http.target", utils.CopyString(c.Path())),
+	))
+	defer span.End()
+
+	c.SetUserContext(ctx)
+	err := c.Next()
+
+	// the span is named after the route label of the metrics
+	route, _ := c.Locals(routeKey).(string)
+	status := c.Response().StatusCode()
+	span.SetName(method + " " + route)
+	span.SetAttributes(attribute.String("http.route", route), attribute.Int("http.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+
+	return err
+}
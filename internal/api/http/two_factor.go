@@ -0,0 +1,378 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CafeKetab/user/internal/models"
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/token"
+	"github.com/CafeKetab/user/pkg/totp"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// loginTwoFactor is the second step of the login for the accounts with the
+// two-factor authentication enabled, it accepts either a totp or a recovery code.
+func (handler *Server) loginTwoFactor(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	request := struct{ Challenge, Code, RecoveryCode string }{}
+	if err := c.BodyParser(&request); err != nil {
+		errString := "Error parsing request body"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
+	}
+
+	hash := token.Hash(request.Challenge)
+
+	challenge, err := handler.repository.FindToken(ctx, hash, models.TokenPurposeLoginChallenge)
+	if err != nil {
+		return fmt.Errorf("error while retrieving the two-factor challenge: %w", err)
+	}
+
+	user, err := handler.repository.FindUserById(ctx, challenge.UserId)
+	if err != nil {
+		errString := "Error while retrieving the user"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", challenge.UserId), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	wait, err := handler.lockout.Check(ctx, user.Email, handler.clientIP(c))
+	if err != nil {
+		errString := "Error while checking the login attempts"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	} else if wait > 0 {
+		errString := "Too many failed login attempts, try again later"
+		logger.FromContext(ctx).Error(errString, zap.String("ip", handler.clientIP(c)), zap.Duration("wait", wait))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		loginsTotal.WithLabelValues(LoginLocked).Inc()
+		return NewAPIError(http.StatusTooManyRequests, CodeTooManyAttempts, errString)
+	}
+
+	// the two-factor authentication may have been disabled since the challenge
+	if !user.TwoFactorEnabled {
+		errString := "Two-factor authentication is not enabled"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id))
+		return NewAPIError(http.StatusUnauthorized, CodeTwoFactorDisabled, errString)
+	}
+
+	ok, err := handler.verifySecondFactor(ctx, user, request.Code, request.RecoveryCode)
+	if err != nil {
+		errString := "Error while verifying the two-factor code"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	} else if !ok {
+		handler.failLogin(ctx, user.Email, handler.clientIP(c))
+
+		errString := "Wrong two-factor code has been given"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", user.Id))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidTwoFactor, errString)
+	}
+
+	if _, err := handler.repository.ConsumeToken(ctx, hash, models.TokenPurposeLoginChallenge); err != nil {
+		return fmt.Errorf("error while retrieving the two-factor challenge: %w", err)
+	}
+
+	if err := handler.lockout.Succeed(ctx, user.Email); err != nil {
+		logger.FromContext(ctx).Error("Error resetting the login attempts", zap.Uint64("id", user.Id), zap.Error(err))
+	}
+
+	// request token
+	token, err := handler.auth.GenerateToken(ctx, user.Id)
+	if err != nil {
+		errString := "Error creating JWT token for user"
+		logger.FromContext(ctx).Error(errString, zap.Any("user", user), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	loginsTotal.WithLabelValues(LoginSuccess).Inc()
+
+	response := map[string]string{"Token": token}
+	return c.Status(http.StatusOK).JSON(&response)
+}
+
+// enrollTwoFactor generates a new totp secret, the two-factor authentication
+// is enabled once a code of the secret is confirmed.
+func (handler *Server) enrollTwoFactor(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	principal, ok := c.Locals(principalKey).(*models.Principal)
+	if !ok {
+		errString := "Error invalid principal for the request"
+		logger.FromContext(ctx).Error(errString, zap.Any("principal", c.Locals(principalKey)))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+	id := principal.Id
+
+	user, err := handler.repository.FindUserById(ctx, id)
+	if err != nil {
+		errString := "Error while retrieving the user"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if user.TwoFactorEnabled {
+		errString := "Two-factor authentication is already enabled"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id))
+		return NewAPIError(http.StatusConflict, CodeTwoFactorEnabled, errString)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		errString := "Error generating the two-factor secret"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	sealed, err := handler.secretbox.Seal(secret)
+	if err != nil {
+		errString := "Error encrypting the two-factor secret"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if err := handler.repository.UpdateTOTP(ctx, id, sealed, false); err != nil {
+		errString := "Error while updating the user"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	response := map[string]string{
+		"Secret": secret,
+		"URI":    totp.URI(handler.config.TwoFactor.Issuer, user.Email, secret),
+	}
+	return c.Status(http.StatusOK).JSON(&response)
+}
+
+// confirmTwoFactor enables the two-factor authentication and returns the recovery codes
+func (handler *Server) confirmTwoFactor(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	principal, ok := c.Locals(principalKey).(*models.Principal)
+	if !ok {
+		errString := "Error invalid principal for the request"
+		logger.FromContext(ctx).Error(errString, zap.Any("principal", c.Locals(principalKey)))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+	id := principal.Id
+
+	request := struct{ Code string }{}
+	if err := c.BodyParser(&request); err != nil {
+		errString := "Error parsing request body"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
+	}
+
+	user, err := handler.repository.FindUserById(ctx, id)
+	if err != nil {
+		errString := "Error while retrieving the user"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if user.TwoFactorEnabled {
+		errString := "Two-factor authentication is already enabled"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id))
+		return NewAPIError(http.StatusConflict, CodeTwoFactorEnabled, errString)
+	} else if len(user.TOTPSecret) == 0 {
+		errString := "Two-factor enrollment has not been started"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id))
+		return NewAPIError(http.StatusBadRequest, CodeTwoFactorPending, errString)
+	}
+
+	secret, err := handler.secretbox.Open(user.TOTPSecret)
+	if err != nil {
+		errString := "Error decrypting the two-factor secret"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	step, ok := totp.Validate(secret, request.Code, time.Now(), handler.config.TwoFactor.Skew)
+	if !ok {
+		errString := "Wrong two-factor code has been given"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidTwoFactor, errString)
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(handler.config.TwoFactor.RecoveryCodes)
+	if err != nil {
+		errString := "Error generating the recovery codes"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, token.Hash(totp.NormalizeRecoveryCode(code)))
+	}
+
+	if err := handler.repository.ReplaceRecoveryCodes(ctx, id, hashes); err != nil {
+		errString := "Error storing the recovery codes"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if err := handler.repository.UpdateTOTP(ctx, id, user.TOTPSecret, true); err != nil {
+		errString := "Error while updating the user"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	// the confirmation code can not be used again for a login
+	if err := handler.repository.UseTOTPStep(ctx, id, step); err != nil {
+		logger.FromContext(ctx).Error("Error recording the used totp step", zap.Uint64("id", id), zap.Error(err))
+	}
+
+	response := map[string][]string{"RecoveryCodes": codes}
+	return c.Status(http.StatusOK).JSON(&response)
+}
+
+// disableTwoFactor requires both the password and a second factor of the user
+func (handler *Server) disableTwoFactor(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	principal, ok := c.Locals(principalKey).(*models.Principal)
+	if !ok {
+		errString := "Error invalid principal for the request"
+		logger.FromContext(ctx).Error(errString, zap.Any("principal", c.Locals(principalKey)))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+	id := principal.Id
+
+	request := struct{ Password, Code, RecoveryCode string }{}
+	if err := c.BodyParser(&request); err != nil {
+		errString := "Error parsing request body"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
+	}
+
+	user, err := handler.repository.FindUserById(ctx, id)
+	if err != nil {
+		errString := "Error while retrieving the user"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if !user.TwoFactorEnabled {
+		errString := "Two-factor authentication is not enabled"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id))
+		return NewAPIError(http.StatusBadRequest, CodeTwoFactorDisabled, errString)
+	}
+
+	// the password and the second factor count as failed logins, so they can
+	// not be guessed here instead of on login
+	wait, err := handler.lockout.Check(ctx, user.Email, handler.clientIP(c))
+	if err != nil {
+		errString := "Error while checking the login attempts"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	} else if wait > 0 {
+		errString := "Too many failed login attempts, try again later"
+		logger.FromContext(ctx).Error(errString, zap.String("ip", handler.clientIP(c)), zap.Duration("wait", wait))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return NewAPIError(http.StatusTooManyRequests, CodeTooManyAttempts, errString)
+	}
+
+	if ok, err := handler.hasher.Verify(request.Password, user.Password); err != nil || !ok {
+		handler.failLogin(ctx, user.Email, handler.clientIP(c))
+
+		errString := "Wrong password has been given"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeWrongPassword, errString)
+	}
+
+	ok, err = handler.verifySecondFactor(ctx, user, request.Code, request.RecoveryCode)
+	if err != nil {
+		errString := "Error while verifying the two-factor code"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id), zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	} else if !ok {
+		handler.failLogin(ctx, user.Email, handler.clientIP(c))
+
+		errString := "Wrong two-factor code has been given"
+		logger.FromContext(ctx).Error(errString, zap.Uint64("id", id))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidTwoFactor, errString)
+	}
+
+	if err := handler.repository.UpdateTOTP(ctx, id, "", false); err != nil {
+		errString := "Error while updating the user"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if err := handler.repository.DeleteRecoveryCodes(ctx, id); err != nil {
+		logger.FromContext(ctx).Error("Error deleting recovery codes", zap.Uint64("id", id), zap.Error(err))
+	}
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// verifySecondFactor checks the totp code, or the recovery code when no totp
+// code is given, both of them are accepted only once.
+func (handler *Server) verifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	if len(code) != 0 {
+		secret, err := handler.secretbox.Open(user.TOTPSecret)
+		if err != nil {
+			return false, err
+		}
+
+		step, ok := totp.Validate(secret, code, time.Now(), handler.config.TwoFactor.Skew)
+		if !ok {
+			return false, nil
+		}
+
+		if err := handler.repository.UseTOTPStep(ctx, user.Id, step); err != nil {
+			if errors.Is(err, repository.ErrTOTPStepUsed) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if len(recoveryCode) != 0 {
+		hash := token.Hash(totp.NormalizeRecoveryCode(recoveryCode))
+		if err := handler.repository.ConsumeRecoveryCode(ctx, user.Id, hash); err != nil {
+			if errors.Is(err, repository.ErrRecoveryCodeNotFound) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// createLoginChallenge returns the token which identifies the user in the second step of the login
+func (handler *Server) createLoginChallenge(ctx context.Context, user *models.User) (string, error) {
+	plain, hash, err := token.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := &models.Token{
+		UserId:    user.Id,
+		Hash:      hash,
+		Purpose:   models.TokenPurposeLoginChallenge,
+		ExpiresAt: time.Now().Add(handler.config.TwoFactor.ChallengeTTL),
+	}
+
+	if err := handler.repository.CreateToken(ctx, challenge); err != nil {
+		return "", err
+	}
+
+	return plain, nil
+}
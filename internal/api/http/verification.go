@@ -0,0 +1,133 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CafeKetab/user/internal/models"
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/mailer"
+	"github.com/CafeKetab/user/pkg/token"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// requestVerification sends a new verification email, the response does not
+// reveal whether an account with the given email exists.
+func (handler *Server) requestVerification(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	request := struct{ Email string }{}
+	if err := c.BodyParser(&request); err != nil {
+		errString := "Error parsing request body"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
+	}
+
+	if len(request.Email) == 0 {
+		errString := "Invalid email has been given"
+		logger.FromContext(ctx).Error(errString)
+		return NewAPIError(http.StatusBadRequest, CodeInvalidEmail, errString)
+	}
+
+	user, err := handler.repository.FindUserByEmail(ctx, request.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return c.SendStatus(http.StatusAccepted)
+		}
+
+		errString := "Error while retrieving data from database"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusInternalServerError, CodeInternal, errString)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return c.SendStatus(http.StatusAccepted)
+	}
+
+	// the email is sent in the background so the response time does not
+	// reveal the existence of the account either, the shutdown waits for it
+	lg := logger.FromContext(ctx)
+	handler.manager.Go("verification email", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), handler.config.Verification.SendTimeout)
+		defer cancel()
+
+		// the failure is only logged, returning it would stop the server
+		if err := handler.sendVerificationEmail(ctx, user); err != nil {
+			lg.Error("Error sending the verification email", zap.Uint64("id", user.Id), zap.Error(err))
+		}
+		return nil
+	})
+
+	return c.SendStatus(http.StatusAccepted)
+}
+
+func (handler *Server) confirmVerification(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	request := struct{ Token string }{}
+	if err := c.BodyParser(&request); err != nil {
+		errString := "Error parsing request body"
+		logger.FromContext(ctx).Error(errString, zap.Error(err))
+		return NewAPIError(http.StatusBadRequest, CodeInvalidBody, errString)
+	}
+
+	// the token is consumed along with the verification, so a failed update
+	// leaves it usable
+	err := handler.repository.WithTx(ctx, func(tx repository.Repository) error {
+		verification, err := tx.ConsumeToken(ctx, token.Hash(request.Token), models.TokenPurposeEmailVerification)
+		if err != nil {
+			return fmt.Errorf("error while retrieving the verification token: %w", err)
+		}
+
+		if err := tx.MarkEmailVerified(ctx, verification.UserId); err != nil {
+			return fmt.Errorf("error while verifying the email: %w", err)
+		}
+
+		if err := tx.DeleteTokens(ctx, verification.UserId, models.TokenPurposeEmailVerification); err != nil {
+			return fmt.Errorf("error while deleting the verification tokens: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(http.StatusOK)
+}
+
+func (handler *Server) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	plain, hash, err := token.Generate()
+	if err != nil {
+		return err
+	}
+
+	verification := &models.Token{
+		UserId:    user.Id,
+		Hash:      hash,
+		Purpose:   models.TokenPurposeEmailVerification,
+		ExpiresAt: time.Now().Add(handler.config.Verification.TokenTTL),
+	}
+
+	if err := handler.repository.CreateToken(ctx, verification); err != nil {
+		return err
+	}
+
+	link := strings.ReplaceAll(handler.config.Verification.URL, "{token}", plain)
+	message := &mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your CafeKetab email address",
+		Body: fmt.Sprintf(
+			"Please verify your email address by opening the link below, it expires in %s.\n\n%s",
+			handler.config.Verification.TokenTTL, link,
+		),
+	}
+
+	return handler.mailer.Send(ctx, message)
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/CafeKetab/user/internal/api/grpc"
+	"github.com/CafeKetab/user/internal/models"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidToken = errors.New("token is invalid or expired")
+
+// Authenticator resolves the bearer tokens issued by the auth service
+type Authenticator interface {
+	// Authenticate returns ErrInvalidToken for the rejected tokens, any other
+	// error means the token could not be checked.
+	Authenticate(ctx context.Context, token string) (*models.Principal, error)
+}
+
+func New(cfg *Config, lg *zap.Logger, client grpc.AuthClient) (Authenticator, error) {
+	switch cfg.Mode {
+	case ModeIntrospection:
+		return NewIntrospection(client), nil
+	case ModeLocal:
+		return NewLocal(cfg, lg)
+	default:
+		return nil, fmt.Errorf("unknown authentication mode: %s", cfg.Mode)
+	}
+}
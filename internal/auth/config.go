@@ -0,0 +1,24 @@
+package auth
+
+import "time"
+
+const (
+	ModeIntrospection = "introspection"
+	ModeLocal         = "local"
+)
+
+type Config struct {
+	// Mode is either introspection, asking the auth service about every
+	// token over grpc, or local, verifying the tokens with the auth service keys.
+	// Only local mode reads the roles and the id of the tokens, introspection
+	// returns the user id alone.
+	Mode string `koanf:"mode"`
+
+	// JWKSURL is the json web key set of the auth service, used in local mode
+	JWKSURL             string        `koanf:"jwks_url"`
+	KeysRefreshInterval time.Duration `koanf:"keys_refresh_interval"`
+
+	// Issuer and Audience are checked in local mode when they are not empty
+	Issuer   string `koanf:"issuer"`
+	Audience string `koanf:"audience"`
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/CafeKetab/user/internal/api/grpc"
+	"github.com/CafeKetab/user/internal/models"
+)
+
+type introspection struct {
+	client grpc.AuthClient
+}
+
+// NewIntrospection asks the auth service about every token, the returned
+// principals only carry the id since the auth service doesn't expose the rest.
+func NewIntrospection(client grpc.AuthClient) Authenticator {
+	return &introspection{client: client}
+}
+
+func (a *introspection) Authenticate(ctx context.Context, token string) (*models.Principal, error) {
+	id, err := a.client.IdFromToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, grpc.ErrInvalidToken) {
+			return nil, ErrInvalidToken
+		}
+
+		return nil, err
+	}
+
+	if id == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return &models.Principal{Id: id}, nil
+}
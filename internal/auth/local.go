@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/CafeKetab/user/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// minimum time between two fetches caused by the requests, whether the
+// previous one failed or not
+const minKeysRefreshInterval = 30 * time.Second
+
+var errKeysUnavailable = errors.New("keys of the auth service are unavailable")
+
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type claims struct {
+	jwt.RegisteredClaims
+
+	// Id is used when the subject is not set
+	Id    uint64   `json:"id,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+type local struct {
+	config *Config
+	logger *zap.Logger
+	client *http.Client
+	parser *jwt.Parser
+
+	mutex     sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+
+	// attemptedAt and refreshErr are of the last refresh, successful or not
+	attemptedAt time.Time
+	refreshErr  error
+}
+
+// NewLocal verifies the signature and claims of the tokens with the public
+// keys of the auth service, which are fetched from its json web key set.
+func NewLocal(cfg *Config, lg *zap.Logger) (Authenticator, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(validMethods)}
+	if len(cfg.Issuer) != 0 {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if len(cfg.Audience) != 0 {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	a := &local{
+		config: cfg,
+		logger: lg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		parser: jwt.NewParser(options...),
+		keys:   make(map[string]any),
+	}
+
+	if err := a.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *local) Authenticate(ctx context.Context, token string) (*models.Principal, error) {
+	parsed := &claims{}
+	if _, err := a.parser.ParseWithClaims(token, parsed, a.keyfunc(ctx)); err != nil {
+		if errors.Is(err, errKeysUnavailable) {
+			return nil, err
+		}
+
+		return nil, ErrInvalidToken
+	}
+
+	// tokens without an expiration would be valid forever
+	if parsed.ExpiresAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	id := parsed.Id
+	if len(parsed.Subject) != 0 {
+		subject, err := strconv.ParseUint(parsed.Subject, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		id = subject
+	}
+
+	if id == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return &models.Principal{Id: id, Roles: parsed.Roles, TokenId: parsed.ID}, nil
+}
+
+func (a *local) keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		a.mutex.Lock()
+		key, exists := a.keys[kid]
+		stale := time.Since(a.fetchedAt) > a.config.KeysRefreshInterval
+		throttled := time.Since(a.attemptedAt) < minKeysRefreshInterval
+		refreshErr := a.refreshErr
+		if (!exists || stale) && !throttled {
+			// claimed before fetching, so the concurrent requests do not
+			// refresh as well
+			a.attemptedAt = time.Now()
+		}
+		a.mutex.Unlock()
+
+		if exists && (!stale || throttled) {
+			// a stale key is used until the next refresh is allowed
+			return key, nil
+		} else if throttled {
+			if refreshErr != nil {
+				return nil, fmt.Errorf("%w: %v", errKeysUnavailable, refreshErr)
+			}
+
+			return nil, fmt.Errorf("unknown key id: %s", kid)
+		}
+
+		if err := a.refresh(ctx); err != nil {
+			a.logger.Error("Error refreshing the auth service keys", zap.Error(err))
+			if !exists {
+				return nil, fmt.Errorf("%w: %v", errKeysUnavailable, err)
+			}
+
+			// keep using the stale key while the auth service is unreachable
+			return key, nil
+		}
+
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+
+		if key, exists = a.keys[kid]; !exists {
+			return nil, fmt.Errorf("unknown key id: %s", kid)
+		}
+
+		return key, nil
+	}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// refresh fetches the keys, the time and the error of the attempt are kept
+// so the failed attempts are throttled too.
+func (a *local) refresh(ctx context.Context) error {
+	keys, err := a.fetch(ctx)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.attemptedAt = time.Now()
+	a.refreshErr = err
+	if err != nil {
+		return err
+	}
+
+	a.keys = keys
+	a.fetchedAt = a.attemptedAt
+
+	return nil
+}
+
+func (a *local) fetch(ctx context.Context) (map[string]any, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, a.config.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating jwks request: %w", err)
+	}
+
+	response, err := a.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jwks: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching jwks: unexpected status %d", response.StatusCode)
+	}
+
+	set := struct{ Keys []jwk }{}
+	if err := json.NewDecoder(response.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("error decoding jwks: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if len(k.Use) != 0 && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			a.logger.Error("Error parsing json web key", zap.String("kid", k.Kid), zap.Error(err))
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
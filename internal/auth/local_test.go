@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// jwks serves the public keys it holds as a json web key set and counts the fetches
+type jwks struct {
+	mutex   sync.Mutex
+	keys    map[string]ed25519.PublicKey
+	failing bool
+	fetches atomic.Int32
+}
+
+func (s *jwks) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.fetches.Add(1)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	set := struct{ Keys []jwk }{}
+	for kid, key := range s.keys {
+		set.Keys = append(set.Keys, jwk{Kty: "OKP", Crv: "Ed25519", Kid: kid, Use: "sig", X: base64.RawURLEncoding.EncodeToString(key)})
+	}
+
+	json.NewEncoder(w).Encode(&set)
+}
+
+func (s *jwks) add(t *testing.T, kid string) ed25519.PrivateKey {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	s.mutex.Lock()
+	s.keys[kid] = public
+	s.mutex.Unlock()
+
+	return private
+}
+
+func (s *jwks) fail(failing bool) {
+	s.mutex.Lock()
+	s.failing = failing
+	s.mutex.Unlock()
+}
+
+func newLocal(t *testing.T) (*local, *jwks) {
+	t.Helper()
+
+	server := &jwks{keys: make(map[string]ed25519.PublicKey)}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	cfg := &Config{Mode: ModeLocal, JWKSURL: httpServer.URL, KeysRefreshInterval: time.Hour}
+	authenticator, err := NewLocal(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("creating authenticator: %v", err)
+	}
+
+	return authenticator.(*local), server
+}
+
+func sign(t *testing.T, kid string, key ed25519.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "42",
+		"jti":   "token-id",
+		"roles": []string{"admin"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// expire makes the keys stale and allows the next refresh
+func (a *local) expire() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.fetchedAt = time.Now().Add(-2 * a.config.KeysRefreshInterval)
+	a.attemptedAt = time.Now().Add(-2 * minKeysRefreshInterval)
+}
+
+func TestLocalAuthenticate(t *testing.T) {
+	a, server := newLocal(t)
+	key := server.add(t, "current")
+	a.expire()
+
+	other := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "valid", token: sign(t, "current", key, validClaims())},
+		{name: "wrong key", token: sign(t, "current", other, validClaims()), err: ErrInvalidToken},
+		{name: "expired", token: sign(t, "current", key, jwt.MapClaims{"sub": "42", "exp": time.Now().Add(-time.Minute).Unix()}), err: ErrInvalidToken},
+		{name: "without expiration", token: sign(t, "current", key, jwt.MapClaims{"sub": "42"}), err: ErrInvalidToken},
+		{name: "without subject", token: sign(t, "current", key, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()}), err: ErrInvalidToken},
+		{name: "malformed", token: "not a token", err: ErrInvalidToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := a.Authenticate(context.Background(), test.token)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			} else if err != nil {
+				return
+			}
+
+			if principal.Id != 42 || principal.TokenId != "token-id" || !principal.HasRole("admin") {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestKeyfuncUnknownKidIsThrottled(t *testing.T) {
+	a, server := newLocal(t)
+	key := server.add(t, "rotated")
+	a.expire()
+
+	// the first unknown kid refreshes the keys
+	if _, err := a.Authenticate(context.Background(), sign(t, "rotated", key, validClaims())); err != nil {
+		t.Fatalf("expected the rotated key to be fetched, got %v", err)
+	}
+
+	fetches := server.fetches.Load()
+	for i := 0; i < 3; i++ {
+		if _, err := a.Authenticate(context.Background(), sign(t, "unknown", key, validClaims())); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected %v, got %v", ErrInvalidToken, err)
+		}
+	}
+
+	if got := server.fetches.Load() - fetches; got != 0 {
+		t.Errorf("expected no fetch within the refresh interval, got %d", got)
+	}
+}
+
+func TestKeyfuncFailedRefreshIsThrottled(t *testing.T) {
+	a, server := newLocal(t)
+	key := server.add(t, "rotated")
+	server.fail(true)
+	a.expire()
+
+	fetches := server.fetches.Load()
+	for i := 0; i < 3; i++ {
+		_, err := a.Authenticate(context.Background(), sign(t, "rotated", key, validClaims()))
+		if !errors.Is(err, errKeysUnavailable) {
+			t.Fatalf("expected %v, got %v", errKeysUnavailable, err)
+		}
+	}
+
+	if got := server.fetches.Load() - fetches; got != 1 {
+		t.Errorf("expected a single fetch after a failure, got %d", got)
+	}
+
+	// the keys are fetched again once the interval has passed
+	server.fail(false)
+	a.expire()
+	if _, err := a.Authenticate(context.Background(), sign(t, "rotated", key, validClaims())); err != nil {
+		t.Fatalf("expected the keys to be fetched again, got %v", err)
+	}
+}
+
+func TestKeyfuncStaleKey(t *testing.T) {
+	a, server := newLocal(t)
+	key := server.add(t, "current")
+	a.expire()
+
+	token := sign(t, "current", key, validClaims())
+	if _, err := a.Authenticate(context.Background(), token); err != nil {
+		t.Fatalf("expected the key to be fetched, got %v", err)
+	}
+
+	server.fail(true)
+	a.expire()
+
+	fetches := server.fetches.Load()
+	for i := 0; i < 3; i++ {
+		if _, err := a.Authenticate(context.Background(), token); err != nil {
+			t.Fatalf("expected the stale key to be used, got %v", err)
+		}
+	}
+
+	if got := server.fetches.Load() - fetches; got != 1 {
+		t.Errorf("expected a single fetch for the stale key, got %d", got)
+	}
+}
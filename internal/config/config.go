@@ -1,10 +1,21 @@
 package config
 
 import (
+	"github.com/CafeKetab/user/internal/api/admin"
 	"github.com/CafeKetab/user/internal/api/grpc"
 	"github.com/CafeKetab/user/internal/api/http"
+	"github.com/CafeKetab/user/internal/auth"
+	"github.com/CafeKetab/user/internal/lockout"
+	"github.com/CafeKetab/user/internal/policy"
+	"github.com/CafeKetab/user/pkg/hasher"
+	"github.com/CafeKetab/user/pkg/health"
+	"github.com/CafeKetab/user/pkg/lifecycle"
 	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/mailer"
 	"github.com/CafeKetab/user/pkg/rdbms"
+	"github.com/CafeKetab/user/pkg/secretbox"
+	"github.com/CafeKetab/user/pkg/tracing"
+	"go.uber.org/zap/zapcore"
 )
 
 type Config struct {
@@ -12,4 +23,22 @@ type Config struct {
 	RDBMS  *rdbms.Config  `koanf:"rdbms"`
 	HTTP   *http.Config   `koanf:"http"`
 	GRPC   *grpc.Config   `koanf:"grpc"`
+	Admin  *admin.Config  `koanf:"admin"`
+	Auth   *auth.Config   `koanf:"auth"`
+	Hasher *hasher.Config `koanf:"hasher"`
+
+	PasswordPolicy *policy.Config  `koanf:"password_policy" reload:"true"`
+	Lockout        *lockout.Config `koanf:"lockout"`
+	Mailer         *mailer.Config  `koanf:"mailer"`
+
+	Encryption *secretbox.Config `koanf:"encryption"`
+
+	Tracing   *tracing.Config   `koanf:"tracing"`
+	Health    *health.Config    `koanf:"health"`
+	Lifecycle *lifecycle.Config `koanf:"lifecycle"`
+}
+
+// MarshalLogObject logs the configuration with its secrets redacted
+func (config *Config) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	return logger.MarshalRedacted(encoder, config)
 }
@@ -1,10 +1,22 @@
 package config
 
 import (
+	"time"
+
+	"github.com/CafeKetab/user/internal/api/admin"
 	"github.com/CafeKetab/user/internal/api/grpc"
 	"github.com/CafeKetab/user/internal/api/http"
+	"github.com/CafeKetab/user/internal/auth"
+	"github.com/CafeKetab/user/internal/lockout"
+	"github.com/CafeKetab/user/internal/policy"
+	"github.com/CafeKetab/user/pkg/hasher"
+	"github.com/CafeKetab/user/pkg/health"
+	"github.com/CafeKetab/user/pkg/lifecycle"
 	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/mailer"
 	"github.com/CafeKetab/user/pkg/rdbms"
+	"github.com/CafeKetab/user/pkg/secretbox"
+	"github.com/CafeKetab/user/pkg/tracing"
 )
 
 func Default() *Config {
@@ -13,19 +25,132 @@ func Default() *Config {
 			Development: true,
 			Level:       "debug",
 			Encoding:    "console",
+			Outputs:     []string{logger.OutputStdout},
+			File: &logger.FileConfig{
+				Path:       "user.log",
+				MaxSize:    100,
+				MaxAge:     28,
+				MaxBackups: 7,
+			},
+			Syslog: &logger.SyslogConfig{Tag: "user"},
+			Sampling: &logger.SamplingConfig{
+				Tick: time.Second,
+				Levels: map[string]*logger.SamplingLevelConfig{
+					"debug": {Initial: 100, Thereafter: 100},
+					"info":  {Initial: 100, Thereafter: 100},
+				},
+			},
 		},
 		RDBMS: &rdbms.Config{
+			Driver:   rdbms.DriverPostgres,
 			Host:     "localhost",
 			Port:     5432,
 			Username: "TEST_USER",
 			Password: "TEST_PASSWORD",
 			Database: "USER_DB",
+			Path:     "user.db",
+
+			Isolation: rdbms.IsolationSerializable,
+			TxRetries: 3,
 		},
 		HTTP: &http.Config{
-			ListenPort: 8081,
+			ListenPort:     8081,
+			TrustedProxies: []string{},
+			ProxyHeader:    "X-Forwarded-For",
+			AdminToken:     "",
+			Verification: &http.VerificationConfig{
+				Required:    false,
+				TokenTTL:    24 * time.Hour,
+				URL:         "http://localhost:3000/verify-email?token={token}",
+				SendTimeout: 30 * time.Second,
+			},
+			PasswordReset: &http.PasswordResetConfig{
+				TokenTTL:    time.Hour,
+				URL:         "http://localhost:3000/reset-password?token={token}",
+				SendTimeout: 30 * time.Second,
+			},
+			TwoFactor: &http.TwoFactorConfig{
+				Issuer:        "CafeKetab",
+				ChallengeTTL:  5 * time.Minute,
+				Skew:          1,
+				RecoveryCodes: 10,
+			},
 		},
 		GRPC: &grpc.Config{
 			AuthGrpcClientAddress: "localhost:9090",
+			ListenPort:            9091,
+			TrustedProxies:        []string{},
+			DefaultTimeout:        5 * time.Second,
+			Reflection:            false,
+			HealthInterval:        5 * time.Second,
+		},
+		Admin: &admin.Config{
+			ListenHost: "127.0.0.1",
+			ListenPort: 8082,
+			Token:      "",
+		},
+		Auth: &auth.Config{
+			Mode:                auth.ModeIntrospection,
+			JWKSURL:             "http://localhost:8080/.well-known/jwks.json",
+			KeysRefreshInterval: time.Hour,
+			Issuer:              "",
+			Audience:            "",
+		},
+		Hasher: &hasher.Config{
+			Algorithm: hasher.Argon2id,
+			Argon2id: &hasher.Argon2idConfig{
+				Memory:      64 * 1024,
+				Iterations:  3,
+				Parallelism: 2,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+			Bcrypt: &hasher.BcryptConfig{
+				Cost: 12,
+			},
+		},
+		PasswordPolicy: &policy.Config{
+			MinLength:     8,
+			MaxLength:     128,
+			RequireUpper:  true,
+			RequireLower:  true,
+			RequireDigit:  true,
+			RequireSymbol: false,
+			ForbidEmail:   true,
+			ForbidCommon:  true,
+		},
+		Lockout: &lockout.Config{
+			Store:            lockout.StoreMemory,
+			MaxFailures:      10,
+			MaxFailuresPerIP: 100,
+			Window:           15 * time.Minute,
+			LockoutDuration:  15 * time.Minute,
+			BackoffAfter:     3,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+		},
+		Mailer: &mailer.Config{
+			Driver:    mailer.DriverLog,
+			From:      "no-reply@cafeketab.ir",
+			Directory: "mails",
+		},
+		Encryption: &secretbox.Config{
+			Key: "",
+		},
+		Tracing: &tracing.Config{
+			Exporter:    tracing.ExporterNone,
+			ServiceName: "user",
+			SampleRatio: 1,
+			Endpoint:    "localhost:4317",
+			Insecure:    true,
+			File:        "traces.json",
+		},
+		Health: &health.Config{
+			Timeout:  2 * time.Second,
+			CacheTTL: 2 * time.Second,
+		},
+		Lifecycle: &lifecycle.Config{
+			ShutdownTimeout: 30 * time.Second,
 		},
 	}
 }
@@ -1,21 +1,39 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/structs"
 	"github.com/knadh/koanf/v2"
+	"github.com/mitchellh/mapstructure"
 )
 
 const (
 	delimeter = "."
 	seperator = "__"
 
-	envPrefix = "AUTH_"
+	envPrefix = "USER_"
+
+	// deprecatedEnvPrefix was copied from the auth service, its variables
+	// collide with the ones of auth in the shared deployments. They're still
+	// read, the USER_ variables take precedence over them.
+	deprecatedEnvPrefix = "AUTH_"
+
+	// secretFileSuffix marks the variables holding the path of a file whose
+	// content is the value, e.g. USER_RDBMS__PASSWORD_FILE=/run/secrets/db.
+	secretFileSuffix = "_FILE"
 
 	tagName = "koanf"
 
@@ -23,42 +41,191 @@ const (
 	bottomTemplate = "======================================================"
 )
 
-func Load(print bool) *Config {
-	k := koanf.New(delimeter)
+// Load loads the defaults, then the file when path is not empty, then the
+// environment variables. It exits with all the errors of the configuration
+// when it is invalid.
+func Load(path string, print bool) *Config {
+	config, err := Read(path)
+	if err != nil {
+		log.Fatalf("error loading config:\n%v", err)
+	}
 
-	// load default configuration from file
-	if err := k.Load(structs.Provider(Default(), "koanf"), nil); err != nil {
-		log.Fatalf("error loading default: %s", err)
+	if print {
+		// pretty print loaded configuration using provided template, the
+		// secrets are redacted so the output is safe to share
+		printed, err := json.MarshalIndent(logger.Redact(config), "", "  ")
+		if err != nil {
+			log.Fatalf("error printing config: %v", err)
+		}
+		log.Printf("%s\n%s\n%s\n", upTemplate, printed, bottomTemplate)
 	}
 
-	if err := loadEnv(k); err != nil {
-		log.Printf("error loading environment variables: %v", err)
+	return config
+}
+
+// Sources of the keys, the source of a key is the last one which has set it
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Read is Load returning the errors instead of exiting
+func Read(path string) (*Config, error) {
+	config, _, err := ReadSources(path)
+	return config, err
+}
+
+// ReadSources is Read which returns the source of every key as well
+func ReadSources(path string) (*Config, map[string]string, error) {
+	k, sources, err := load(path)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	config := Config{}
-	var tag = koanf.UnmarshalConf{Tag: tagName}
-	if err := k.UnmarshalWithConf("", &config, tag); err != nil {
-		log.Fatalf("error unmarshalling config: %v", err)
+	if err := k.UnmarshalWithConf("", &config, unmarshalConf(&config)); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling config: %v", err)
 	}
 
-	if print {
-		// pretty print loaded configuration using provided template
-		log.Printf("%s\n%v\n%s\n", upTemplate, spew.Sdump(config), bottomTemplate)
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
 	}
 
-	return &config
+	return &config, sources, nil
 }
 
-func loadEnv(k *koanf.Koanf) error {
-	callback := func(source string) string {
-		base := strings.ToLower(strings.TrimPrefix(source, envPrefix))
-		return strings.ReplaceAll(base, seperator, delimeter)
+// load merges every source into a koanf instance of its own first, so the
+// keys it has set are known.
+func load(path string) (*koanf.Koanf, map[string]string, error) {
+	k := koanf.New(delimeter)
+	sources := make(map[string]string)
+
+	merge := func(layer *koanf.Koanf, source string) error {
+		for _, key := range layer.Keys() {
+			sources[key] = source
+		}
+		return k.Merge(layer)
+	}
+
+	defaults := koanf.New(delimeter)
+	if err := defaults.Load(structs.Provider(Default(), tagName), nil); err != nil {
+		return nil, nil, fmt.Errorf("error loading default: %v", err)
+	}
+	if err := merge(defaults, SourceDefault); err != nil {
+		return nil, nil, fmt.Errorf("error loading default: %v", err)
+	}
+
+	if len(path) != 0 {
+		layer := koanf.New(delimeter)
+		if err := loadFile(layer, path); err != nil {
+			return nil, nil, err
+		}
+		if err := merge(layer, SourceFile); err != nil {
+			return nil, nil, fmt.Errorf("error loading config file %s: %v", path, err)
+		}
 	}
 
-	// load environment variables
-	if err := k.Load(env.Provider(envPrefix, delimeter, callback), nil); err != nil {
-		return fmt.Errorf("error loading environment variables: %s", err)
+	layer := koanf.New(delimeter)
+	if err := loadEnv(layer, k.Exists); err != nil {
+		return nil, nil, err
+	}
+	if err := merge(layer, SourceEnv); err != nil {
+		return nil, nil, fmt.Errorf("error loading environment variables: %v", err)
+	}
+
+	return k, sources, nil
+}
+
+// unmarshalConf decodes the comma separated lists of the environment
+// variables into slices, e.g. USER_LOGGER__OUTPUTS=stdout,file
+func unmarshalConf(config *Config) koanf.UnmarshalConf {
+	return koanf.UnmarshalConf{
+		Tag: tagName,
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				mapstructure.TextUnmarshallerHookFunc(),
+			),
+			Result:           config,
+			TagName:          tagName,
+			WeaklyTypedInput: true,
+		},
+	}
+}
+
+func loadFile(k *koanf.Koanf, path string) error {
+	var parser koanf.Parser
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		parser = yaml.Parser()
+	case ".toml":
+		parser = toml.Parser()
+	default:
+		return fmt.Errorf("config file %s is neither yaml nor toml", path)
+	}
+
+	if err := k.Load(file.Provider(path), parser); err != nil {
+		return fmt.Errorf("error loading config file %s: %v", path, err)
 	}
 
 	return nil
 }
+
+// deprecationWarning logs the deprecated variables once, on the first load
+var deprecationWarning sync.Once
+
+// loadEnv loads the environment variables into k, exists reports whether a
+// key is known, which tells the keys ending in _FILE apart from the secret files.
+func loadEnv(k *koanf.Koanf, exists func(key string) bool) error {
+	var errs, deprecated []string
+
+	for _, prefix := range []string{deprecatedEnvPrefix, envPrefix} {
+		prefix := prefix
+		callback := func(name, value string) (string, any) {
+			key := envKey(prefix, name)
+			if !exists(key) && strings.HasSuffix(name, secretFileSuffix) {
+				key = envKey(prefix, strings.TrimSuffix(name, secretFileSuffix))
+
+				content, err := os.ReadFile(value)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("error reading %s: %v", name, err))
+					return "", nil
+				}
+				value = strings.TrimRight(string(content), "\r\n")
+			}
+
+			if prefix == deprecatedEnvPrefix {
+				deprecated = append(deprecated, name)
+			}
+
+			return key, value
+		}
+
+		// load environment variables
+		if err := k.Load(env.ProviderWithValue(prefix, delimeter, callback), nil); err != nil {
+			return fmt.Errorf("error loading environment variables: %s", err)
+		}
+	}
+
+	// the environment does not change, so the reloads would only repeat it
+	if len(deprecated) != 0 {
+		deprecationWarning.Do(func() {
+			log.Printf("the %s prefix of the environment variables is deprecated, use %s instead: %s",
+				deprecatedEnvPrefix, envPrefix, strings.Join(deprecated, ", "))
+		})
+	}
+
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// envKey is the key of the environment variable, e.g. rdbms.password of USER_RDBMS__PASSWORD
+func envKey(prefix, name string) string {
+	base := strings.ToLower(strings.TrimPrefix(name, prefix))
+	return strings.ReplaceAll(base, seperator, delimeter)
+}
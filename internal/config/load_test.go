@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+		source   string
+	}{
+		{name: "default", env: map[string]string{}, expected: "debug", source: SourceDefault},
+		{name: "user", env: map[string]string{"USER_LOGGER__LEVEL": "warn"}, expected: "warn", source: SourceEnv},
+		{name: "deprecated", env: map[string]string{"AUTH_LOGGER__LEVEL": "error"}, expected: "error", source: SourceEnv},
+		{
+			name:     "user over deprecated",
+			env:      map[string]string{"AUTH_LOGGER__LEVEL": "error", "USER_LOGGER__LEVEL": "warn"},
+			expected: "warn", source: SourceEnv,
+		},
+	}
+
+	captureLog(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			config, sources, err := ReadSources("")
+			if err != nil {
+				t.Fatalf("reading config: %v", err)
+			}
+
+			if config.Logger.Level != test.expected || sources["logger.level"] != test.source {
+				t.Errorf("expected %s from %s, got %s from %s", test.expected, test.source, config.Logger.Level, sources["logger.level"])
+			}
+		})
+	}
+}
+
+func TestFilePrecedence(t *testing.T) {
+	setRequiredEnv(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "logger:\n  level: info\nrdbms:\n  port: 6543\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	t.Setenv("USER_LOGGER__LEVEL", "warn")
+
+	config, sources, err := ReadSources(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+
+	if config.RDBMS.Port != 6543 || sources["rdbms.port"] != SourceFile {
+		t.Errorf("expected the port of the file, got %d from %s", config.RDBMS.Port, sources["rdbms.port"])
+	}
+
+	if config.Logger.Level != "warn" || sources["logger.level"] != SourceEnv {
+		t.Errorf("expected the environment over the file, got %s from %s", config.Logger.Level, sources["logger.level"])
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+		return path
+	}
+
+	t.Run("read", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("USER_RDBMS__PASSWORD_FILE", write("password", "s3cret\n"))
+
+		config, err := Read("")
+		if err != nil {
+			t.Fatalf("reading config: %v", err)
+		}
+
+		if config.RDBMS.Password != "s3cret" {
+			t.Errorf("expected the content of the file without the newline, got %q", config.RDBMS.Password)
+		}
+	})
+
+	t.Run("deprecated prefix", func(t *testing.T) {
+		captureLog(t)
+		setRequiredEnv(t)
+		t.Setenv("AUTH_RDBMS__PASSWORD_FILE", write("deprecated", "old"))
+
+		if config, err := Read(""); err != nil || config.RDBMS.Password != "old" {
+			t.Errorf("expected the content of the file, got %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		setRequiredEnv(t)
+		t.Setenv("USER_RDBMS__PASSWORD_FILE", filepath.Join(dir, "missing"))
+
+		if _, err := Read(""); err == nil || !strings.Contains(err.Error(), "USER_RDBMS__PASSWORD_FILE") {
+			t.Errorf("expected an error naming the variable, got %v", err)
+		}
+	})
+}
+
+func TestValidateAllErrors(t *testing.T) {
+	config := Default()
+	config.Logger.Level = "loud"
+	config.HTTP.ListenPort = 70000
+	config.GRPC.ListenPort = 9091
+	config.GRPC.AuthToken = ""
+	config.Encryption.Key = publishedKey
+	config.Admin.ListenHost = "0.0.0.0"
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatalf("expected the config to be invalid")
+	}
+
+	expected := []string{
+		"logger.level:",
+		"http.listen_port: 70000 is not a valid port",
+		"grpc.auth_token: is required",
+		"encryption.key: the former default key is public",
+		"admin.token: is required",
+	}
+	for _, message := range expected {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("expected %q among the errors, got:\n%v", message, err)
+		}
+	}
+
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != len(expected) {
+		t.Errorf("expected %d errors, one per line, got:\n%v", len(expected), err)
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	setRequiredEnv(t)
+	if _, err := Read(""); err != nil {
+		t.Fatalf("expected the defaults with the required settings to be valid, got %v", err)
+	}
+
+	// the defaults alone lack them
+	if err := Default().Validate(); err == nil || !strings.Contains(err.Error(), "encryption.key") || !strings.Contains(err.Error(), "grpc.auth_token") {
+		t.Errorf("expected the required settings to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/knadh/koanf/maps"
+	"github.com/knadh/koanf/providers/structs"
+	"github.com/knadh/koanf/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of Print
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Print writes the configuration with its secrets redacted. The YAML has the
+// layout of the configuration file and its keys are commented with their
+// source, the JSON maps every key to its value and source, e.g.
+// {"rdbms.port": {"value": 5432, "source": "default"}}.
+func Print(w io.Writer, config *Config, sources map[string]string, format string) error {
+	redacted, ok := logger.Redact(config).(map[string]any)
+	if !ok {
+		return fmt.Errorf("error redacting config")
+	}
+
+	switch format {
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(yamlNode(redacted, "", sources)); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case FormatJSON:
+		type entry struct {
+			Value  any    `json:"value"`
+			Source string `json:"source"`
+		}
+
+		flat, _ := maps.Flatten(redacted, nil, delimeter)
+		entries := make(map[string]entry, len(flat))
+		for key, value := range flat {
+			entries[key] = entry{Value: value, Source: sources[key]}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	default:
+		return fmt.Errorf("unknown format %q, it must be %s or %s", format, FormatYAML, FormatJSON)
+	}
+}
+
+func yamlNode(value any, prefix string, sources map[string]string) *yaml.Node {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			node = &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(value)}
+		}
+		return node
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keys {
+		path := key
+		if len(prefix) != 0 {
+			path = prefix + delimeter + key
+		}
+
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+		valueNode := yamlNode(fields[key], path, sources)
+		if source, ok := sources[path]; ok && valueNode.Kind != yaml.MappingNode {
+			keyNode.LineComment = source
+		}
+
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+
+	return node
+}
+
+// EnvVar is an environment variable of the configuration
+type EnvVar struct {
+	Name    string
+	Key     string
+	Default string
+}
+
+// EnvVars returns the environment variables of every key with their default
+// value, the secrets are redacted.
+func EnvVars() ([]EnvVar, error) {
+	k := koanf.New(delimeter)
+	if err := k.Load(structs.Provider(Default(), tagName), nil); err != nil {
+		return nil, fmt.Errorf("error loading default: %v", err)
+	}
+
+	redacted, ok := logger.Redact(Default()).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("error redacting config")
+	}
+	defaults, _ := maps.Flatten(redacted, nil, delimeter)
+
+	keys := k.Keys()
+	sort.Strings(keys)
+
+	vars := make([]EnvVar, 0, len(keys))
+	for _, key := range keys {
+		vars = append(vars, EnvVar{Name: EnvName(key), Key: key, Default: envValue(defaults[key])})
+	}
+
+	return vars, nil
+}
+
+// EnvName is the environment variable of the key, e.g. USER_RDBMS__PASSWORD of rdbms.password
+func EnvName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, delimeter, seperator))
+}
+
+func envValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			values = append(values, fmt.Sprint(item))
+		}
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
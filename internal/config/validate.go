@@ -0,0 +1,312 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CafeKetab/user/internal/auth"
+	"github.com/CafeKetab/user/internal/lockout"
+	"github.com/CafeKetab/user/pkg/hasher"
+	"github.com/CafeKetab/user/pkg/logger"
+	"github.com/CafeKetab/user/pkg/mailer"
+	"github.com/CafeKetab/user/pkg/rdbms"
+	"github.com/CafeKetab/user/pkg/tracing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var levels = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+
+// publishedKey was the default encryption key, it's public so it's rejected
+// from the deployments which kept it.
+const publishedKey = "Q2FmZUtldGFiX0RldmVsb3BtZW50X0tleV8zMl9CISE="
+
+// Validate checks the whole configuration and returns all of its errors at
+// once, every error is prefixed with the key of the invalid setting.
+func (config *Config) Validate() error {
+	v := &validator{}
+
+	if lg := config.Logger; v.present("logger", lg != nil) {
+		v.oneOf("logger.level", lg.Level, levels...)
+		v.oneOf("logger.encoding", lg.Encoding, "console", "json")
+		v.check("logger.outputs", len(lg.Outputs) != 0, "at least one output is required")
+		for _, output := range lg.Outputs {
+			v.oneOf("logger.outputs", output, logger.OutputStdout, logger.OutputStderr, logger.OutputFile, logger.OutputSyslog)
+			if output == logger.OutputFile && v.present("logger.file", lg.File != nil) {
+				v.check("logger.file.path", len(lg.File.Path) != 0, "is required by the file output")
+				v.nonNegative("logger.file.max_size", lg.File.MaxSize)
+				v.nonNegative("logger.file.max_age", lg.File.MaxAge)
+				v.nonNegative("logger.file.max_backups", lg.File.MaxBackups)
+			}
+		}
+		if sampling := lg.Sampling; sampling != nil && len(sampling.Levels) != 0 {
+			v.positiveDuration("logger.sampling.tick", sampling.Tick)
+			for level, levelCfg := range sampling.Levels {
+				key := "logger.sampling.levels." + level
+				v.oneOf(key, level, levels...)
+				if levelCfg != nil {
+					v.nonNegative(key+".initial", levelCfg.Initial)
+					v.nonNegative(key+".thereafter", levelCfg.Thereafter)
+				}
+			}
+		}
+	}
+
+	if db := config.RDBMS; v.present("rdbms", db != nil) {
+		v.oneOf("rdbms.driver", db.Driver, rdbms.DriverPostgres, rdbms.DriverMysql, rdbms.DriverSqlite)
+		if db.Driver == rdbms.DriverSqlite {
+			v.check("rdbms.path", len(db.Path) != 0, "is required by sqlite")
+		} else {
+			v.host("rdbms.host", db.Host)
+			v.port("rdbms.port", db.Port, false)
+			v.check("rdbms.database", len(db.Database) != 0, "is required")
+			v.check("rdbms.username", len(db.Username) != 0, "is required")
+		}
+		// validated by the parser of rdbms, which accepts empty for the default of the database
+		_, err := rdbms.ParseIsolation(db.Isolation)
+		v.check("rdbms.isolation", err == nil, "%q must be empty or one of %s", db.Isolation,
+			strings.Join([]string{rdbms.IsolationReadCommitted, rdbms.IsolationRepeatableRead, rdbms.IsolationSerializable}, ", "))
+		v.nonNegative("rdbms.tx_retries", db.TxRetries)
+	}
+
+	if http := config.HTTP; v.present("http", http != nil) {
+		v.port("http.listen_port", http.ListenPort, false)
+		v.proxies("http.trusted_proxies", http.TrustedProxies)
+		if len(http.TrustedProxies) != 0 {
+			v.check("http.proxy_header", len(http.ProxyHeader) != 0, "is required by the trusted proxies")
+		}
+		if verification := http.Verification; v.present("http.verification", verification != nil) {
+			v.positiveDuration("http.verification.token_ttl", verification.TokenTTL)
+			v.tokenURL("http.verification.url", verification.URL)
+			v.positiveDuration("http.verification.send_timeout", verification.SendTimeout)
+		}
+		if reset := http.PasswordReset; v.present("http.password_reset", reset != nil) {
+			v.positiveDuration("http.password_reset.token_ttl", reset.TokenTTL)
+			v.tokenURL("http.password_reset.url", reset.URL)
+			v.positiveDuration("http.password_reset.send_timeout", reset.SendTimeout)
+		}
+		if twoFactor := http.TwoFactor; v.present("http.two_factor", twoFactor != nil) {
+			v.check("http.two_factor.issuer", len(twoFactor.Issuer) != 0, "is required")
+			v.positiveDuration("http.two_factor.challenge_ttl", twoFactor.ChallengeTTL)
+			v.check("http.two_factor.skew", twoFactor.Skew >= 0, "must not be negative")
+			v.check("http.two_factor.recovery_codes", twoFactor.RecoveryCodes > 0, "must be positive")
+		}
+	}
+
+	if grpc := config.GRPC; v.present("grpc", grpc != nil) {
+		v.hostPort("grpc.auth_grpc_client_address", grpc.AuthGrpcClientAddress)
+		v.port("grpc.listen_port", grpc.ListenPort, true)
+		v.positiveDuration("grpc.default_timeout", grpc.DefaultTimeout)
+		v.positiveDuration("grpc.health_interval", grpc.HealthInterval)
+		v.check("grpc.auth_token", grpc.ListenPort == 0 || len(grpc.AuthToken) != 0, "is required unless the grpc server is disabled")
+		v.proxies("grpc.trusted_proxies", grpc.TrustedProxies)
+	}
+
+	if admin := config.Admin; v.present("admin", admin != nil) {
+		v.port("admin.listen_port", admin.ListenPort, true)
+		if admin.ListenPort != 0 {
+			v.check("admin.listen_host", len(admin.ListenHost) != 0, "is required, 0.0.0.0 listens on every interface")
+			ip := net.ParseIP(admin.ListenHost)
+			loopback := admin.ListenHost == "localhost" || (ip != nil && ip.IsLoopback())
+			v.check("admin.token", loopback || len(admin.Token) != 0, "is required unless admin.listen_host is the loopback")
+		}
+	}
+
+	if config.HTTP != nil && config.GRPC != nil && config.Admin != nil {
+		v.distinctPorts(map[string]int{
+			"http.listen_port":  config.HTTP.ListenPort,
+			"grpc.listen_port":  config.GRPC.ListenPort,
+			"admin.listen_port": config.Admin.ListenPort,
+		})
+	}
+
+	if authCfg := config.Auth; v.present("auth", authCfg != nil) {
+		v.oneOf("auth.mode", authCfg.Mode, auth.ModeIntrospection, auth.ModeLocal)
+		if authCfg.Mode == auth.ModeLocal {
+			v.url("auth.jwks_url", authCfg.JWKSURL)
+			v.positiveDuration("auth.keys_refresh_interval", authCfg.KeysRefreshInterval)
+		}
+	}
+
+	if hash := config.Hasher; v.present("hasher", hash != nil) {
+		v.oneOf("hasher.algorithm", hash.Algorithm, hasher.Argon2id, hasher.Bcrypt)
+		if argon := hash.Argon2id; hash.Algorithm == hasher.Argon2id && v.present("hasher.argon2id", argon != nil) {
+			v.check("hasher.argon2id.memory", argon.Memory > 0 && argon.Memory <= hasher.Argon2idMaxMemory,
+				"must be between 1 and %d", hasher.Argon2idMaxMemory)
+			v.check("hasher.argon2id.iterations", argon.Iterations > 0 && argon.Iterations <= hasher.Argon2idMaxIterations,
+				"must be between 1 and %d", hasher.Argon2idMaxIterations)
+			v.check("hasher.argon2id.parallelism", argon.Parallelism > 0, "must be positive")
+			v.check("hasher.argon2id.salt_length", argon.SaltLength >= hasher.Argon2idMinSaltLength && argon.SaltLength <= hasher.Argon2idMaxLength,
+				"must be between %d and %d", hasher.Argon2idMinSaltLength, hasher.Argon2idMaxLength)
+			v.check("hasher.argon2id.key_length", argon.KeyLength >= hasher.Argon2idMinKeyLength && argon.KeyLength <= hasher.Argon2idMaxLength,
+				"must be between %d and %d", hasher.Argon2idMinKeyLength, hasher.Argon2idMaxLength)
+		}
+		if bcryptCfg := hash.Bcrypt; hash.Algorithm == hasher.Bcrypt && v.present("hasher.bcrypt", bcryptCfg != nil) {
+			v.check("hasher.bcrypt.cost", bcryptCfg.Cost >= bcrypt.MinCost && bcryptCfg.Cost <= bcrypt.MaxCost,
+				"must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	}
+
+	if policy := config.PasswordPolicy; v.present("password_policy", policy != nil) {
+		v.check("password_policy.min_length", policy.MinLength > 0, "must be positive")
+		v.check("password_policy.max_length", policy.MaxLength >= policy.MinLength, "must not be less than min_length")
+	}
+
+	if lock := config.Lockout; v.present("lockout", lock != nil) {
+		v.oneOf("lockout.store", lock.Store, lockout.StoreMemory, lockout.StoreRDBMS)
+		v.check("lockout.max_failures", lock.MaxFailures > 0, "must be positive")
+		v.check("lockout.max_failures_per_ip", lock.MaxFailuresPerIP > 0, "must be positive")
+		v.positiveDuration("lockout.window", lock.Window)
+		v.positiveDuration("lockout.lockout_duration", lock.LockoutDuration)
+		v.nonNegative("lockout.backoff_after", lock.BackoffAfter)
+		v.positiveDuration("lockout.base_delay", lock.BaseDelay)
+		v.check("lockout.max_delay", lock.MaxDelay >= lock.BaseDelay, "must not be less than base_delay")
+	}
+
+	if mailCfg := config.Mailer; v.present("mailer", mailCfg != nil) {
+		v.oneOf("mailer.driver", mailCfg.Driver, mailer.DriverLog, mailer.DriverFile)
+		_, err := mail.ParseAddress(mailCfg.From)
+		v.check("mailer.from", err == nil, "is not a valid email address")
+		if mailCfg.Driver == mailer.DriverFile {
+			v.check("mailer.directory", len(mailCfg.Directory) != 0, "is required by the file driver")
+		}
+	}
+
+	if encryption := config.Encryption; v.present("encryption", encryption != nil) {
+		key, err := base64.StdEncoding.DecodeString(encryption.Key)
+		v.check("encryption.key", err == nil && len(key) == 32, "must be a base64 encoded 32 byte key")
+		v.check("encryption.key", encryption.Key != publishedKey, "the former default key is public, set a key of your own")
+	}
+
+	if trace := config.Tracing; v.present("tracing", trace != nil) {
+		v.oneOf("tracing.exporter", trace.Exporter, tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterFile)
+		v.check("tracing.service_name", len(trace.ServiceName) != 0, "is required")
+		v.check("tracing.sample_ratio", trace.SampleRatio >= 0 && trace.SampleRatio <= 1, "must be between 0 and 1")
+		switch trace.Exporter {
+		case tracing.ExporterOTLP:
+			v.hostPort("tracing.endpoint", trace.Endpoint)
+		case tracing.ExporterFile:
+			v.check("tracing.file", len(trace.File) != 0, "is required by the file exporter")
+		}
+	}
+
+	if health := config.Health; v.present("health", health != nil) {
+		v.positiveDuration("health.timeout", health.Timeout)
+		v.check("health.cache_ttl", health.CacheTTL >= 0, "must not be negative")
+	}
+
+	if lifecycle := config.Lifecycle; v.present("lifecycle", lifecycle != nil) {
+		v.positiveDuration("lifecycle.shutdown_timeout", lifecycle.ShutdownTimeout)
+	}
+
+	return v.err()
+}
+
+// validator collects the errors of the configuration
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(key string, ok bool, format string, args ...any) bool {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	return ok
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+func (v *validator) present(key string, ok bool) bool {
+	return v.check(key, ok, "is required")
+}
+
+func (v *validator) oneOf(key, value string, values ...string) {
+	for _, allowed := range values {
+		if value == allowed {
+			return
+		}
+	}
+
+	v.check(key, false, "%q must be one of %s", value, strings.Join(values, ", "))
+}
+
+func (v *validator) nonNegative(key string, value int) {
+	v.check(key, value >= 0, "must not be negative")
+}
+
+func (v *validator) positiveDuration(key string, value time.Duration) {
+	v.check(key, value > 0, "must be a positive duration")
+}
+
+// port checks the port is in range, zero is accepted for the optional ones
+// which disables them.
+func (v *validator) port(key string, port int, optional bool) {
+	if optional && port == 0 {
+		return
+	}
+
+	v.check(key, port > 0 && port <= 65535, "%d is not a valid port", port)
+}
+
+func (v *validator) distinctPorts(ports map[string]int) {
+	keys := make(map[int]string, len(ports))
+	for _, key := range []string{"http.listen_port", "grpc.listen_port", "admin.listen_port"} {
+		port, ok := ports[key]
+		if !ok || port == 0 {
+			continue
+		}
+
+		if other, ok := keys[port]; ok {
+			v.check(key, false, "%d is already used by %s", port, other)
+			continue
+		}
+		keys[port] = key
+	}
+}
+
+func (v *validator) host(key, host string) {
+	if !v.check(key, len(host) != 0, "is required") {
+		return
+	}
+
+	v.check(key, !strings.ContainsAny(host, " /:") || net.ParseIP(host) != nil, "%q is not a valid host", host)
+}
+
+// proxies checks the trusted proxies are addresses or CIDR ranges
+func (v *validator) proxies(key string, proxies []string) {
+	for _, proxy := range proxies {
+		_, _, err := net.ParseCIDR(proxy)
+		v.check(key, err == nil || net.ParseIP(proxy) != nil, "%q is neither an ip nor a CIDR range", proxy)
+	}
+}
+
+func (v *validator) hostPort(key, address string) {
+	host, port, err := net.SplitHostPort(address)
+	if !v.check(key, err == nil, "%q must be host:port", address) {
+		return
+	}
+
+	number, err := strconv.Atoi(port)
+	v.check(key, err == nil && number > 0 && number <= 65535 && len(host) != 0, "%q must be host:port", address)
+}
+
+func (v *validator) url(key, value string) bool {
+	parsed, err := url.Parse(value)
+	return v.check(key, err == nil && len(parsed.Scheme) != 0 && len(parsed.Host) != 0, "%q is not a valid url", value)
+}
+
+// tokenURL checks the url of the links sent to the users
+func (v *validator) tokenURL(key, value string) {
+	if v.url(key, value) {
+		v.check(key, strings.Contains(value, "{token}"), "must contain {token}")
+	}
+}
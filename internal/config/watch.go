@@ -0,0 +1,281 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/knadh/koanf/providers/structs"
+	"github.com/knadh/koanf/v2"
+	"go.uber.org/zap"
+)
+
+// reloadDelay coalesces the events of a single save of the file, editors
+// usually truncate and write it in separate steps.
+const reloadDelay = 100 * time.Millisecond
+
+// Watcher reloads the configuration on SIGHUP and whenever its file changes.
+// Only the settings tagged `reload:"true"`, and the settings under them, are
+// changed live, the changes of the others are logged and ignored until a
+// restart.
+type Watcher struct {
+	path   string
+	logger *zap.Logger
+
+	mutex       sync.Mutex
+	current     *Config
+	subscribers []func(previous, next *Config) error
+
+	hup  chan os.Signal
+	done chan struct{}
+	file *fsnotify.Watcher
+	stop sync.Once
+}
+
+func NewWatcher(path string, current *Config, lg *zap.Logger) *Watcher {
+	return &Watcher{
+		path:    path,
+		logger:  lg,
+		current: current,
+		hup:     make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Subscribe calls fn with the setting selected from the configuration every
+// time a reload changes it, the errors of fn are logged.
+func Subscribe[T any](watcher *Watcher, selector func(*Config) T, fn func(T) error) {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	watcher.subscribers = append(watcher.subscribers, func(previous, next *Config) error {
+		value := selector(next)
+		if reflect.DeepEqual(selector(previous), value) {
+			return nil
+		}
+
+		return fn(value)
+	})
+}
+
+// Current returns the configuration of the last reload
+func (watcher *Watcher) Current() *Config {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	return watcher.current
+}
+
+// Reload reads and validates the configuration again, then publishes the
+// changes of the reloadable settings to the subscribers.
+func (watcher *Watcher) Reload() error {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+
+	loaded, err := Read(watcher.path)
+	if err != nil {
+		watcher.logger.Error("Error reloading config, the current one is kept", zap.Error(err))
+		return err
+	}
+
+	next, changed, ignored, err := merge(watcher.current, loaded)
+	if err != nil {
+		watcher.logger.Error("Error reloading config, the current one is kept", zap.Error(err))
+		return err
+	}
+
+	for _, key := range ignored {
+		watcher.logger.Warn("Ignoring the change of a setting which is not reloadable, restart to apply it", zap.String("setting", key))
+	}
+
+	if len(changed) == 0 {
+		watcher.logger.Info("config reloaded without changes")
+		return nil
+	}
+
+	previous := watcher.current
+	watcher.current = next
+
+	for _, subscriber := range watcher.subscribers {
+		if err := subscriber(previous, next); err != nil {
+			watcher.logger.Error("Error applying reloaded config", zap.Error(err))
+		}
+	}
+
+	watcher.logger.Info("config reloaded", zap.Strings("changed", changed))
+	return nil
+}
+
+// Start reloads on SIGHUP, and on the changes of the file when there is one
+func (watcher *Watcher) Start(context.Context) error {
+	if len(watcher.path) != 0 {
+		file, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("Error watching config file\n%v", err)
+		}
+
+		// the directory is watched since the file may be replaced, e.g. by
+		// editors or the kubernetes config maps
+		if err := file.Add(filepath.Dir(watcher.path)); err != nil {
+			file.Close()
+			return fmt.Errorf("Error watching config file\n%v", err)
+		}
+		watcher.file = file
+	}
+
+	signal.Notify(watcher.hup, syscall.SIGHUP)
+	go watcher.watch()
+
+	return nil
+}
+
+func (watcher *Watcher) watch() {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if watcher.file != nil {
+		events, errs = watcher.file.Events, watcher.file.Errors
+	}
+
+	var delay <-chan time.Time
+	for {
+		select {
+		case <-watcher.done:
+			return
+		case <-watcher.hup:
+			watcher.logger.Info("reloading config on SIGHUP")
+			watcher.Reload()
+		case event := <-events:
+			if watcher.changed(event) && delay == nil {
+				delay = time.After(reloadDelay)
+			}
+		case <-delay:
+			delay = nil
+			watcher.logger.Info("reloading config on a change of the file", zap.String("path", watcher.path))
+			watcher.Reload()
+		case err := <-errs:
+			watcher.logger.Error("Error watching config file", zap.Error(err))
+		}
+	}
+}
+
+func (watcher *Watcher) changed(event fsnotify.Event) bool {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+		return false
+	}
+
+	return filepath.Clean(event.Name) == filepath.Clean(watcher.path) || filepath.Base(event.Name) == "..data"
+}
+
+// Stop stops watching, the reload in progress if any is completed. It can be
+// called more than once, the later calls do nothing.
+func (watcher *Watcher) Stop(context.Context) (err error) {
+	watcher.stop.Do(func() {
+		signal.Stop(watcher.hup)
+		close(watcher.done)
+
+		if watcher.file != nil {
+			err = watcher.file.Close()
+		}
+	})
+
+	return err
+}
+
+// merge returns current with the reloadable settings of loaded, along with
+// the keys which have changed and the ones which are ignored.
+func merge(current, loaded *Config) (_ *Config, changed, ignored []string, err error) {
+	k := koanf.New(delimeter)
+	if err := k.Load(structs.Provider(current, tagName), nil); err != nil {
+		return nil, nil, nil, err
+	}
+
+	next := koanf.New(delimeter)
+	if err := next.Load(structs.Provider(loaded, tagName), nil); err != nil {
+		return nil, nil, nil, err
+	}
+
+	keys := k.Keys()
+	for _, key := range next.Keys() {
+		if !k.Exists(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	reloadable := reloadableKeys(reflect.TypeOf(Config{}), "")
+	for _, key := range keys {
+		if reflect.DeepEqual(k.Get(key), next.Get(key)) {
+			continue
+		}
+
+		if !isReloadable(reloadable, key) {
+			ignored = append(ignored, key)
+			continue
+		}
+
+		changed = append(changed, key)
+		if next.Exists(key) {
+			err = k.Set(key, next.Get(key))
+		} else {
+			k.Delete(key)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	merged := Config{}
+	if err := k.UnmarshalWithConf("", &merged, unmarshalConf(&merged)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &merged, changed, ignored, nil
+}
+
+// reloadableKeys returns the keys of the fields tagged `reload:"true"`
+func reloadableKeys(t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		key := field.Tag.Get(tagName)
+		if len(prefix) != 0 {
+			key = prefix + delimeter + key
+		}
+
+		if field.Tag.Get("reload") == "true" {
+			keys = append(keys, key)
+		} else {
+			keys = append(keys, reloadableKeys(field.Type, key)...)
+		}
+	}
+
+	return keys
+}
+
+func isReloadable(reloadable []string, key string) bool {
+	for _, prefix := range reloadable {
+		if key == prefix || strings.HasPrefix(key, prefix+delimeter) {
+			return true
+		}
+	}
+
+	return false
+}
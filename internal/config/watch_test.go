@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// setRequiredEnv sets the settings which have no default
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("USER_ENCRYPTION__KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	t.Setenv("USER_GRPC__AUTH_TOKEN", "token")
+}
+
+// captureLog returns the output of the standard logger until the end of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var output bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&output)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	return &output
+}
+
+func TestDeprecationWarningOnce(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("AUTH_LOGGER__LEVEL", "info")
+	output := captureLog(t)
+	deprecationWarning = sync.Once{}
+
+	current, err := Read("")
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+
+	watcher := NewWatcher("", current, zap.NewNop())
+	for i := 0; i < 3; i++ {
+		if err := watcher.Reload(); err != nil {
+			t.Fatalf("reloading config: %v", err)
+		}
+	}
+
+	if count := strings.Count(output.String(), "AUTH_LOGGER__LEVEL"); count != 1 {
+		t.Errorf("expected the deprecation to be logged once, got %d times:\n%s", count, output)
+	}
+}
+
+func TestWatcherStopTwice(t *testing.T) {
+	watcher := NewWatcher("", Default(), zap.NewNop())
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("starting watcher: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := watcher.Stop(context.Background()); err != nil {
+			t.Errorf("stopping watcher: %v", err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package lockout
+
+import "time"
+
+const (
+	StoreMemory = "memory"
+	StoreRDBMS  = "rdbms"
+)
+
+type Config struct {
+	// Store is where attempts are kept, memory for a single instance and rdbms for clusters
+	Store string `koanf:"store"`
+
+	MaxFailures      int           `koanf:"max_failures" reload:"true"`
+	MaxFailuresPerIP int           `koanf:"max_failures_per_ip" reload:"true"`
+	Window           time.Duration `koanf:"window" reload:"true"`
+	LockoutDuration  time.Duration `koanf:"lockout_duration" reload:"true"`
+
+	// exponential backoff applied after BackoffAfter failures until the lockout
+	BackoffAfter int           `koanf:"backoff_after" reload:"true"`
+	BaseDelay    time.Duration `koanf:"base_delay" reload:"true"`
+	MaxDelay     time.Duration `koanf:"max_delay" reload:"true"`
+}
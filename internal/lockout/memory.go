@@ -0,0 +1,67 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// number of saves between two sweeps of the expired attempts
+const sweepInterval = 1024
+
+type memoryStore struct {
+	mutex    sync.Mutex
+	attempts map[string]Attempt
+	saves    int
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{attempts: make(map[string]Attempt)}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (*Attempt, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	attempt, exists := s.attempts[key]
+	if !exists {
+		return nil, nil
+	}
+
+	return &attempt, nil
+}
+
+func (s *memoryStore) Update(_ context.Context, key string, fn func(attempt *Attempt) *Attempt) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var current *Attempt
+	if attempt, exists := s.attempts[key]; exists {
+		current = &attempt
+	}
+	s.attempts[key] = *fn(current)
+
+	if s.saves++; s.saves%sweepInterval == 0 {
+		s.sweep(time.Now())
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep drops the attempts which are not blocking anymore and whose last
+// failure is older than a day, it must be called while holding the mutex.
+func (s *memoryStore) sweep(now time.Time) {
+	for key, attempt := range s.attempts {
+		if now.After(attempt.BlockedUntil) && now.Sub(attempt.FirstFailureAt) > 24*time.Hour {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CafeKetab/user/pkg/rdbms"
+)
+
+type rdbmsStore struct {
+	rdbms       rdbms.RDBMS
+	insertQuery string
+}
+
+// NewRDBMSStore keeps the attempts in the login_attempts table so every
+// instance of the service shares them.
+func NewRDBMSStore(rdbms rdbms.RDBMS) Store {
+	insertQuery := rdbms.Dialect().InsertIgnore("login_attempts", attemptColumns)
+	return &rdbmsStore{rdbms: rdbms, insertQuery: insertQuery}
+}
+
+// attemptColumns are inserted with the statement of the dialect
+var attemptColumns = []string{"attempt_key", "failures", "first_failure_at", "blocked_until"}
+
+const QueryGetAttempt = `
+	SELECT failures, first_failure_at, blocked_until
+	FROM login_attempts
+	WHERE attempt_key=$1;`
+
+func (s *rdbmsStore) Get(ctx context.Context, key string) (*Attempt, error) {
+	return getAttempt(ctx, s.rdbms, QueryGetAttempt, key)
+}
+
+const QueryLockAttempt = `
+	SELECT failures, first_failure_at, blocked_until
+	FROM login_attempts
+	WHERE attempt_key=$1
+	FOR UPDATE;`
+
+const QueryUpdateAttempt = `
+	UPDATE login_attempts
+	SET failures=$2, first_failure_at=$3, blocked_until=$4
+	WHERE attempt_key=$1;`
+
+// Update inserts an empty attempt when the key has none, so there is always a
+// row to lock, then updates the locked row. The concurrent updates of the key
+// wait for the lock instead of overwriting each other.
+func (s *rdbmsStore) Update(ctx context.Context, key string, fn func(attempt *Attempt) *Attempt) error {
+	return s.rdbms.WithTx(ctx, func(tx rdbms.Executor) error {
+		if err := tx.Update(ctx, s.insertQuery, []any{key, 0, time.Now().UnixMilli(), 0}); err != nil {
+			return err
+		}
+
+		attempt, err := getAttempt(ctx, tx, QueryLockAttempt, key)
+		if err != nil {
+			return err
+		}
+
+		attempt = fn(attempt)
+
+		args := []any{key, attempt.Failures, attempt.FirstFailureAt.UnixMilli(), attempt.BlockedUntil.UnixMilli()}
+		return tx.Update(ctx, QueryUpdateAttempt, args)
+	})
+}
+
+func getAttempt(ctx context.Context, executor rdbms.Executor, query, key string) (*Attempt, error) {
+	var failures int
+	var firstFailureAt, blockedUntil int64
+
+	args := []any{key}
+	dest := []any{&failures, &firstFailureAt, &blockedUntil}
+	if err := executor.Read(ctx, query, args, dest); err != nil {
+		if errors.Is(err, rdbms.ErrReadNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	attempt := &Attempt{
+		Failures:       failures,
+		FirstFailureAt: time.UnixMilli(firstFailureAt),
+		BlockedUntil:   time.UnixMilli(blockedUntil),
+	}
+
+	return attempt, nil
+}
+
+const QueryDeleteAttempt = "DELETE FROM login_attempts WHERE attempt_key=$1;"
+
+func (s *rdbmsStore) Delete(ctx context.Context, key string) error {
+	return s.rdbms.Delete(ctx, QueryDeleteAttempt, []any{key})
+}
@@ -0,0 +1,25 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Attempt is the failed login history of a single key within the window
+type Attempt struct {
+	Failures       int
+	FirstFailureAt time.Time
+	BlockedUntil   time.Time
+}
+
+type Store interface {
+	// Get returns nil without an error when there is no attempt for the key
+	Get(ctx context.Context, key string) (*Attempt, error)
+
+	// Update replaces the attempt of the key with the one returned by fn, which
+	// gets nil when there is none. The read and the write are atomic, so the
+	// concurrent failures are all counted.
+	Update(ctx context.Context, key string, fn func(attempt *Attempt) *Attempt) error
+
+	Delete(ctx context.Context, key string) error
+}
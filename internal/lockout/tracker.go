@@ -0,0 +1,133 @@
+package lockout
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Tracker counts the failed logins per email and per client ip, slowing down
+// the callers with an exponential backoff and locking them out after too many
+// failures within the window. The per ip limit is skipped when the ip is empty.
+type Tracker interface {
+	// Check returns how long the caller has to wait before trying again,
+	// zero means the login attempt is allowed.
+	Check(ctx context.Context, email, ip string) (time.Duration, error)
+
+	Fail(ctx context.Context, email, ip string) error
+
+	// Succeed forgets the failures of the email
+	Succeed(ctx context.Context, email string) error
+
+	// Unlock lifts the lockout of the email
+	Unlock(ctx context.Context, email string) error
+
+	// Update replaces the limits, the failures counted so far are kept. The
+	// store can not be changed.
+	Update(cfg *Config)
+}
+
+type tracker struct {
+	config atomic.Pointer[Config]
+	store  Store
+	now    func() time.Time
+}
+
+func NewTracker(cfg *Config, store Store) Tracker {
+	t := &tracker{store: store, now: time.Now}
+	t.config.Store(cfg)
+
+	return t
+}
+
+func (t *tracker) Update(cfg *Config) {
+	t.config.Store(cfg)
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (t *tracker) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := t.now()
+
+	keys := []string{emailKey(email)}
+	if len(ip) != 0 {
+		keys = append(keys, ipKey(ip))
+	}
+
+	var wait time.Duration
+	for _, key := range keys {
+		attempt, err := t.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		} else if attempt == nil {
+			continue
+		}
+
+		if remaining := attempt.BlockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+
+	return wait, nil
+}
+
+func (t *tracker) Fail(ctx context.Context, email, ip string) error {
+	config := t.config.Load()
+
+	if err := t.fail(ctx, config, emailKey(email), config.MaxFailures); err != nil {
+		return err
+	} else if len(ip) == 0 {
+		return nil
+	}
+
+	return t.fail(ctx, config, ipKey(ip), config.MaxFailuresPerIP)
+}
+
+func (t *tracker) fail(ctx context.Context, config *Config, key string, maxFailures int) error {
+	now := t.now()
+
+	return t.store.Update(ctx, key, func(attempt *Attempt) *Attempt {
+		// start over when the window of the previous failures has passed
+		if attempt == nil || (now.Sub(attempt.FirstFailureAt) > config.Window && now.After(attempt.BlockedUntil)) {
+			attempt = &Attempt{FirstFailureAt: now}
+		}
+
+		attempt.Failures++
+
+		if attempt.Failures >= maxFailures {
+			attempt.BlockedUntil = now.Add(config.LockoutDuration)
+		} else if attempt.Failures >= config.BackoffAfter {
+			attempt.BlockedUntil = now.Add(backoff(config, attempt.Failures-config.BackoffAfter))
+		}
+
+		return attempt
+	})
+}
+
+func backoff(config *Config, exponent int) time.Duration {
+	delay := config.BaseDelay
+	for i := 0; i < exponent && delay < config.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > config.MaxDelay {
+		return config.MaxDelay
+	}
+
+	return delay
+}
+
+func (t *tracker) Succeed(ctx context.Context, email string) error {
+	return t.store.Delete(ctx, emailKey(email))
+}
+
+func (t *tracker) Unlock(ctx context.Context, email string) error {
+	return t.store.Delete(ctx, emailKey(email))
+}
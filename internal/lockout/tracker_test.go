@@ -0,0 +1,221 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CafeKetab/user/internal/repository"
+	"github.com/CafeKetab/user/pkg/rdbms"
+	"go.uber.org/zap"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Store:            StoreMemory,
+		MaxFailures:      5,
+		MaxFailuresPerIP: 8,
+		Window:           15 * time.Minute,
+		LockoutDuration:  time.Hour,
+		BackoffAfter:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         4 * time.Second,
+	}
+}
+
+// newTracker returns a tracker whose clock only moves with the returned function
+func newTracker(t *testing.T, cfg *Config, store Store) (Tracker, func(time.Duration)) {
+	t.Helper()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := NewTracker(cfg, store).(*tracker)
+	tr.now = func() time.Time { return now }
+
+	return tr, func(d time.Duration) { now = now.Add(d) }
+}
+
+func check(t *testing.T, tr Tracker, email, ip string) time.Duration {
+	t.Helper()
+
+	wait, err := tr.Check(context.Background(), email, ip)
+	if err != nil {
+		t.Fatalf("checking: %v", err)
+	}
+
+	return wait
+}
+
+func fail(t *testing.T, tr Tracker, email, ip string, times int) {
+	t.Helper()
+
+	for i := 0; i < times; i++ {
+		if err := tr.Fail(context.Background(), email, ip); err != nil {
+			t.Fatalf("failing: %v", err)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(t *testing.T, tr Tracker, advance func(time.Duration))
+		expected time.Duration
+	}{
+		{
+			name:     "allowed below the backoff",
+			run:      func(t *testing.T, tr Tracker, _ func(time.Duration)) { fail(t, tr, "a@b.co", "1.1.1.1", 2) },
+			expected: 0,
+		},
+		{
+			name:     "backoff",
+			run:      func(t *testing.T, tr Tracker, _ func(time.Duration)) { fail(t, tr, "a@b.co", "1.1.1.1", 3) },
+			expected: time.Second,
+		},
+		{
+			name:     "backoff doubles",
+			run:      func(t *testing.T, tr Tracker, _ func(time.Duration)) { fail(t, tr, "a@b.co", "1.1.1.1", 4) },
+			expected: 2 * time.Second,
+		},
+		{
+			name:     "locked out",
+			run:      func(t *testing.T, tr Tracker, _ func(time.Duration)) { fail(t, tr, "a@b.co", "1.1.1.1", 5) },
+			expected: time.Hour,
+		},
+		{
+			name:     "email is case insensitive",
+			run:      func(t *testing.T, tr Tracker, _ func(time.Duration)) { fail(t, tr, " A@B.co", "2.2.2.2", 5) },
+			expected: time.Hour,
+		},
+		{
+			name: "window passed",
+			run: func(t *testing.T, tr Tracker, advance func(time.Duration)) {
+				fail(t, tr, "a@b.co", "1.1.1.1", 2)
+				advance(16 * time.Minute)
+				fail(t, tr, "a@b.co", "1.1.1.1", 2)
+			},
+			expected: 0,
+		},
+		{
+			name: "lockout expired",
+			run: func(t *testing.T, tr Tracker, advance func(time.Duration)) {
+				fail(t, tr, "a@b.co", "1.1.1.1", 5)
+				advance(time.Hour + time.Second)
+			},
+			expected: 0,
+		},
+		{
+			name: "per ip across emails",
+			run: func(t *testing.T, tr Tracker, _ func(time.Duration)) {
+				for _, email := range []string{"a@x.co", "b@x.co", "c@x.co", "d@x.co"} {
+					fail(t, tr, email, "1.1.1.1", 2)
+				}
+			},
+			expected: time.Hour,
+		},
+		{
+			name: "no ip is not counted per ip",
+			run: func(t *testing.T, tr Tracker, _ func(time.Duration)) {
+				for _, email := range []string{"a@x.co", "b@x.co", "c@x.co", "d@x.co"} {
+					fail(t, tr, email, "", 2)
+				}
+			},
+			expected: 0,
+		},
+		{
+			name: "succeed forgets the email",
+			run: func(t *testing.T, tr Tracker, _ func(time.Duration)) {
+				fail(t, tr, "a@b.co", "", 5)
+				tr.Succeed(context.Background(), "a@b.co")
+			},
+			expected: 0,
+		},
+		{
+			name: "unlock lifts the lockout",
+			run: func(t *testing.T, tr Tracker, _ func(time.Duration)) {
+				fail(t, tr, "a@b.co", "", 5)
+				tr.Unlock(context.Background(), "a@b.co")
+			},
+			expected: 0,
+		},
+		{
+			name: "updated limits",
+			run: func(t *testing.T, tr Tracker, _ func(time.Duration)) {
+				cfg := testConfig()
+				cfg.MaxFailures = 2
+				tr.Update(cfg)
+				fail(t, tr, "a@b.co", "", 2)
+			},
+			expected: time.Hour,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr, advance := newTracker(t, testConfig(), NewMemoryStore())
+			test.run(t, tr, advance)
+
+			// the checks are made with an unrelated ip or email, so only the other key blocks
+			if wait := max(check(t, tr, "a@b.co", "9.9.9.9"), check(t, tr, "z@b.co", "1.1.1.1")); wait != test.expected {
+				t.Errorf("expected to wait %s, got %s", test.expected, wait)
+			}
+		})
+	}
+}
+
+func max(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// testConcurrentFailures fails the same email from many goroutines at once,
+// every failure must be counted.
+func testConcurrentFailures(t *testing.T, store Store) {
+	cfg := testConfig()
+	cfg.MaxFailures, cfg.MaxFailuresPerIP, cfg.BackoffAfter = 1000, 1000, 1000
+	tr, _ := newTracker(t, cfg, store)
+
+	const failures = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < failures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tr.Fail(context.Background(), "a@b.co", "1.1.1.1"); err != nil {
+				t.Errorf("failing: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range []string{emailKey("a@b.co"), ipKey("1.1.1.1")} {
+		attempt, err := store.Get(context.Background(), key)
+		if err != nil {
+			t.Fatalf("getting attempt: %v", err)
+		} else if attempt == nil || attempt.Failures != failures {
+			t.Errorf("expected %d failures of %s, got %+v", failures, key, attempt)
+		}
+	}
+}
+
+func TestMemoryStoreConcurrentFailures(t *testing.T) {
+	testConcurrentFailures(t, NewMemoryStore())
+}
+
+func TestRDBMSStoreConcurrentFailures(t *testing.T) {
+	db, err := rdbms.New(&rdbms.Config{Driver: rdbms.DriverSqlite, Path: rdbms.Memory, TxRetries: 3})
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := repository.NewMigrator(zap.NewNop(), db).Up(context.Background(), false); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	testConcurrentFailures(t, NewRDBMSStore(db))
+}
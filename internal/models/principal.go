@@ -0,0 +1,19 @@
+package models
+
+// Principal is the authenticated caller of a request. Roles and TokenId are
+// only known in the local auth mode, they are empty with introspection.
+type Principal struct {
+	Id      uint64
+	Roles   []string
+	TokenId string
+}
+
+func (principal *Principal) HasRole(role string) bool {
+	for _, r := range principal.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeLoginChallenge    = "login_challenge"
+)
+
+// Token is a single-use token sent to the user, only its hash is stored
+type Token struct {
+	Id        uint64
+	UserId    uint64
+	Hash      string
+	Purpose   string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
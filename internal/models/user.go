@@ -1,12 +1,29 @@
 package models
 
+import (
+	"time"
+
+	"github.com/CafeKetab/user/pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
 type User struct {
-	Id        uint64 `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email,omitempty"`
-	Password  string `json:"password,omitempty"`
-	CreatedAt string `json:"created_at,omitempty"`
+	Id        uint64 `json:"id" db:"id"`
+	FirstName string `json:"first_name" db:"first_name"`
+	LastName  string `json:"last_name" db:"last_name"`
+	Email     string `json:"email,omitempty" db:"email"`
+	Password  string `json:"password,omitempty" db:"password"`
+	CreatedAt string `json:"created_at,omitempty" db:"created_at"`
+
+	EmailVerifiedAt  *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled,omitempty" db:"totp_enabled"`
+
+	// TOTPSecret is the encrypted secret of the two-factor authentication
+	TOTPSecret string `json:"-" db:"totp_secret"`
+
+	// PasswordResetRequired is set for accounts whose password was stored in
+	// plaintext, they can not login until they choose a new password.
+	PasswordResetRequired bool `json:"-" db:"password_reset_required"`
 }
 
 func (user *User) Marshall(isPublic bool) *User {
@@ -14,7 +31,30 @@ func (user *User) Marshall(isPublic bool) *User {
 	if isPublic {
 		user.Email = ""
 		user.CreatedAt = ""
+		user.EmailVerifiedAt = nil
+		user.TwoFactorEnabled = false
 	}
 
 	return user
 }
+
+// MarshalLogObject logs the user without its secrets, the email is hashed so
+// the log lines of the user can still be correlated.
+func (user *User) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddUint64("id", user.Id)
+	encoder.AddString("first_name", user.FirstName)
+	encoder.AddString("last_name", user.LastName)
+	if len(user.Email) != 0 {
+		encoder.AddString("email", logger.HashEmail(user.Email))
+	}
+	if len(user.CreatedAt) != 0 {
+		encoder.AddString("created_at", user.CreatedAt)
+	}
+	if user.EmailVerifiedAt != nil {
+		encoder.AddTime("email_verified_at", *user.EmailVerifiedAt)
+	}
+	encoder.AddBool("two_factor_enabled", user.TwoFactorEnabled)
+	encoder.AddBool("password_reset_required", user.PasswordResetRequired)
+
+	return nil
+}
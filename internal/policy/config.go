@@ -0,0 +1,12 @@
+package policy
+
+type Config struct {
+	MinLength     int  `koanf:"min_length"`
+	MaxLength     int  `koanf:"max_length"`
+	RequireUpper  bool `koanf:"require_upper"`
+	RequireLower  bool `koanf:"require_lower"`
+	RequireDigit  bool `koanf:"require_digit"`
+	RequireSymbol bool `koanf:"require_symbol"`
+	ForbidEmail   bool `koanf:"forbid_email"`
+	ForbidCommon  bool `koanf:"forbid_common"`
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUpper     = "upper"
+	RuleLower     = "lower"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleEmail     = "email"
+	RuleCommon    = "common"
+)
+
+// minimum length of the email local part to be checked against the password,
+// shorter ones are too likely to appear by chance.
+const minEmailLocalPartLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// Violation describes a single failed rule of the policy
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type PasswordPolicy interface {
+	// Validate checks the password against every rule and returns all the
+	// violations, an empty result means the password is acceptable.
+	Validate(password, email string) []Violation
+
+	// Update replaces the rules, the passwords validated afterwards are
+	// checked against the new ones.
+	Update(cfg *Config)
+}
+
+type passwordPolicy struct {
+	config atomic.Pointer[Config]
+	common map[string]struct{}
+}
+
+func NewPasswordPolicy(cfg *Config) PasswordPolicy {
+	policy := &passwordPolicy{common: make(map[string]struct{})}
+	policy.config.Store(cfg)
+
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) != 0 {
+			policy.common[strings.ToLower(line)] = struct{}{}
+		}
+	}
+
+	return policy
+}
+
+func (p *passwordPolicy) Update(cfg *Config) {
+	p.config.Store(cfg)
+}
+
+func (p *passwordPolicy) Validate(password, email string) []Violation {
+	config := p.config.Load()
+
+	violations := []Violation{}
+	add := func(rule, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < config.MinLength {
+		add(RuleMinLength, "password must be at least %d characters long", config.MinLength)
+	}
+
+	if config.MaxLength > 0 && length > config.MaxLength {
+		add(RuleMaxLength, "password must be at most %d characters long", config.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if config.RequireUpper && !hasUpper {
+		add(RuleUpper, "password must contain an uppercase letter")
+	}
+
+	if config.RequireLower && !hasLower {
+		add(RuleLower, "password must contain a lowercase letter")
+	}
+
+	if config.RequireDigit && !hasDigit {
+		add(RuleDigit, "password must contain a digit")
+	}
+
+	if config.RequireSymbol && !hasSymbol {
+		add(RuleSymbol, "password must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+
+	if config.ForbidEmail {
+		localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+		if len(localPart) >= minEmailLocalPartLength && strings.Contains(lowered, localPart) {
+			add(RuleEmail, "password must not contain the email address")
+		}
+	}
+
+	if config.ForbidCommon {
+		if _, exists := p.common[lowered]; exists {
+			add(RuleCommon, "password is too common")
+		}
+	}
+
+	return violations
+}
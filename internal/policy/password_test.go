@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func strictConfig() *Config {
+	return &Config{
+		MinLength:     10,
+		MaxLength:     20,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		ForbidEmail:   true,
+		ForbidCommon:  true,
+	}
+}
+
+func rules(violations []Violation) []string {
+	result := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		result = append(result, violation.Rule)
+	}
+
+	return result
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *Config
+		password string
+		email    string
+		expected []string
+	}{
+		{name: "valid", config: strictConfig(), password: "Correct-Horse-9", email: "user@example.com", expected: []string{}},
+		{name: "too short", config: strictConfig(), password: "Ab1-", email: "user@example.com", expected: []string{RuleMinLength}},
+		{name: "too long", config: strictConfig(), password: "Correct-Horse-Battery-9", email: "user@example.com", expected: []string{RuleMaxLength}},
+		{name: "length in runes", config: strictConfig(), password: "Äöü-ßéè-Çñ1", email: "user@example.com", expected: []string{}},
+		{name: "no upper", config: strictConfig(), password: "correct-horse-9", email: "user@example.com", expected: []string{RuleUpper}},
+		{name: "no lower", config: strictConfig(), password: "CORRECT-HORSE-9", email: "user@example.com", expected: []string{RuleLower}},
+		{name: "no digit", config: strictConfig(), password: "Correct-Horse-X", email: "user@example.com", expected: []string{RuleDigit}},
+		{name: "no symbol", config: strictConfig(), password: "CorrectHorse9", email: "user@example.com", expected: []string{RuleSymbol}},
+		{name: "space is a symbol", config: strictConfig(), password: "Correct Horse 9", email: "user@example.com", expected: []string{}},
+		{name: "contains email", config: strictConfig(), password: "Xx-Johnny-9", email: "johnny@example.com", expected: []string{RuleEmail}},
+		{name: "contains email case insensitively", config: strictConfig(), password: "Xx-JOHNNY-9", email: "Johnny@example.com", expected: []string{RuleEmail}},
+		{name: "short local part is ignored", config: strictConfig(), password: "Correct-Jo-9", email: "jo@example.com", expected: []string{}},
+		{name: "common", config: &Config{ForbidCommon: true}, password: "password", expected: []string{RuleCommon}},
+		{name: "common case insensitively", config: &Config{ForbidCommon: true}, password: "QWERTY", expected: []string{RuleCommon}},
+		{name: "common allowed", config: &Config{}, password: "password", expected: []string{}},
+		{name: "no max length", config: &Config{MinLength: 1}, password: "a very long password of many many characters", expected: []string{}},
+		{
+			name: "every violation", config: strictConfig(), password: "qwerty", email: "qwerty@example.com",
+			expected: []string{RuleMinLength, RuleUpper, RuleDigit, RuleSymbol, RuleEmail, RuleCommon},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violations := NewPasswordPolicy(test.config).Validate(test.password, test.email)
+			if got := rules(violations); fmt.Sprint(got) != fmt.Sprint(test.expected) {
+				t.Errorf("expected violations %v, got %v", test.expected, got)
+			}
+
+			for _, violation := range violations {
+				if len(violation.Message) == 0 {
+					t.Errorf("expected a message for the %s violation", violation.Rule)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	policy := NewPasswordPolicy(&Config{MinLength: 8})
+	if violations := policy.Validate("short", ""); len(violations) != 1 {
+		t.Fatalf("expected a violation, got %v", violations)
+	}
+
+	policy.Update(&Config{MinLength: 4})
+	if violations := policy.Validate("short", ""); len(violations) != 0 {
+		t.Fatalf("expected the updated rules, got %v", violations)
+	}
+}
+
+// TestConcurrentUpdate checks every validation sees one of the configs as a
+// whole, run it with -race.
+func TestConcurrentUpdate(t *testing.T) {
+	lenient, strict := &Config{MinLength: 1}, strictConfig()
+	policy := NewPasswordPolicy(lenient)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				policy.Update(strict)
+			} else {
+				policy.Update(lenient)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			// the lenient config has no violation and the strict one has all four
+			if n := len(policy.Validate("abc", "")); n != 0 && n != 4 {
+				t.Errorf("expected the violations of a single config, got %d", n)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+}
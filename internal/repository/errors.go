@@ -0,0 +1,16 @@
+package repository
+
+import "errors"
+
+// Domain errors of the repository, the errors of rdbms are translated into
+// them so the callers don't depend on the database.
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email is already taken")
+
+	// ErrTokenNotFound is returned for unknown, used and expired tokens alike
+	ErrTokenNotFound = errors.New("token not found")
+
+	ErrTOTPStepUsed         = errors.New("totp code has already been used")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+)
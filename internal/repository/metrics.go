@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "user",
+		Subsystem: "repository",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of the repository methods by method name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	operationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "user",
+		Subsystem: "repository",
+		Name:      "operation_errors_total",
+		Help:      "Failed repository methods by method name, the domain errors are not failures.",
+	}, []string{"method"})
+)
+
+var tracer = otel.Tracer("github.com/CafeKetab/user/internal/repository")
+
+// domainErrors are the expected outcomes of the methods, they're not counted as failures
+var domainErrors = []error{
+	ErrUserNotFound, ErrEmailTaken, ErrTokenNotFound, ErrTOTPStepUsed, ErrRecoveryCodeNotFound,
+}
+
+// observe starts the span of a method, the returned function ends it and
+// records the duration and the failure of the method. It's deferred with the
+// address of the named error result of the method.
+func (r *repository) observe(ctx context.Context, method string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "repository."+method)
+
+	return ctx, func(err *error) {
+		defer span.End()
+		operationDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+		if *err == nil {
+			return
+		}
+
+		for _, domainErr := range domainErrors {
+			if errors.Is(*err, domainErr) {
+				return
+			}
+		}
+
+		operationErrors.WithLabelValues(method).Inc()
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+}
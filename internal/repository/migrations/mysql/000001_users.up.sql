@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users(
+	id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+	first_name VARCHAR(30),
+	last_name VARCHAR(30),
+	email VARCHAR(50) NOT NULL,
+	password VARCHAR(30) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX users_email_idx ON users (email);
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN password_reset_required;
+
+UPDATE users SET password=LEFT(password, 30);
+ALTER TABLE users MODIFY COLUMN password VARCHAR(30) NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE users MODIFY COLUMN password VARCHAR(255) NOT NULL;
+
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME NULL;
+
+CREATE TABLE IF NOT EXISTS verification_tokens(
+	id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+	user_id BIGINT UNSIGNED NOT NULL,
+	token_hash VARCHAR(64) NOT NULL UNIQUE,
+	purpose VARCHAR(30) NOT NULL,
+	expires_at DATETIME NOT NULL,
+	used_at DATETIME NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX verification_tokens_user_purpose_idx ON verification_tokens (user_id, purpose);
@@ -0,0 +1,3 @@
+DROP INDEX users_email_key ON users;
+
+CREATE INDEX users_email_idx ON users (email);
@@ -0,0 +1,3 @@
+DROP INDEX users_email_idx ON users;
+
+CREATE UNIQUE INDEX users_email_key ON users (email);
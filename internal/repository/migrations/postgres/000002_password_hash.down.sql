@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_required;
+
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(30) USING LEFT(password, 30);
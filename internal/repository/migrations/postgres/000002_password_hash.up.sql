@@ -0,0 +1,3 @@
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
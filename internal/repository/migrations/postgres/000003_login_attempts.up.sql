@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS login_attempts(
+	attempt_key VARCHAR(320) PRIMARY KEY,
+	failures INTEGER NOT NULL DEFAULT 0,
+	first_failure_at BIGINT NOT NULL,
+	blocked_until BIGINT NOT NULL DEFAULT 0
+);
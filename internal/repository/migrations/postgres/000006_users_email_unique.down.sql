@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_email_key;
+
+CREATE INDEX IF NOT EXISTS users_email_idx ON users (email);
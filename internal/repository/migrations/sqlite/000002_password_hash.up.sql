@@ -0,0 +1,2 @@
+-- sqlite does not enforce the length of VARCHAR, so only the new column is needed
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS verification_tokens;
+
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,444 @@
+package repository
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CafeKetab/user/pkg/rdbms"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"go.uber.org/zap"
+)
+
+// migrations has a directory of migrations per dialect, named after the dialect
+//
+//go:embed migrations
+var migrations embed.FS
+
+// Directions of the Step
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Migration is a version of the schema
+type Migration struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// Step is a migration which is applied in the given direction
+type Step struct {
+	Version   uint
+	Name      string
+	Direction string
+	Query     string
+}
+
+var ErrDirty = errors.New("database is dirty, fix the failed migration and force its version")
+
+// Migrator applies the embedded migrations of the dialect of the database,
+// the methods changing the schema return the steps they have applied, or the
+// steps they would apply without applying them when dryRun is set.
+type Migrator interface {
+	// Status returns every migration with whether it has been applied
+	Status(ctx context.Context) ([]Migration, error)
+
+	// Version returns the current version, zero when no migration has been applied
+	Version(ctx context.Context) (version uint, dirty bool, err error)
+
+	Up(ctx context.Context, dryRun bool) ([]Step, error)
+
+	Down(ctx context.Context, dryRun bool) ([]Step, error)
+
+	// Steps applies the next n migrations, or reverts the last -n ones when n is negative
+	Steps(ctx context.Context, n int, dryRun bool) ([]Step, error)
+
+	// Goto applies or reverts the migrations until the given version is reached
+	Goto(ctx context.Context, version uint, dryRun bool) ([]Step, error)
+
+	// Force sets the version without running any migration and clears the dirty
+	// flag, -1 means no version.
+	Force(ctx context.Context, version int) error
+
+	// Check fails unless the database is clean and at the latest embedded
+	// version, it reads the version table directly instead of waiting for the
+	// migration lock, so it's cheap enough for the readiness probes.
+	Check(ctx context.Context) error
+}
+
+type migrator struct {
+	logger *zap.Logger
+	rdbms  rdbms.RDBMS
+	dir    string
+}
+
+func NewMigrator(lg *zap.Logger, rdbms rdbms.RDBMS) Migrator {
+	return &migrator{logger: lg, rdbms: rdbms, dir: "migrations/" + rdbms.Dialect().Name()}
+}
+
+func (m *migrator) Status(ctx context.Context) ([]Migration, error) {
+	version, _, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := m.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Migration, 0, len(versions))
+	for _, migration := range versions {
+		migration.Applied = migration.Version <= version
+		result = append(result, migration)
+	}
+
+	return result, nil
+}
+
+func (m *migrator) Version(ctx context.Context) (uint, bool, error) {
+	var version uint
+	var dirty bool
+
+	err := m.rdbms.Migrate(ctx, migrations, m.dir, func(migration *migrate.Migrate) error {
+		var err error
+		version, dirty, err = migration.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		m.logger.Error("Error reading the migration version", zap.Error(err))
+		return 0, false, err
+	}
+
+	return version, dirty, nil
+}
+
+func (m *migrator) Up(ctx context.Context, dryRun bool) ([]Step, error) {
+	target := func(current, count int) (int, error) { return count, nil }
+	return m.run(ctx, dryRun, target, (*migrate.Migrate).Up)
+}
+
+func (m *migrator) Down(ctx context.Context, dryRun bool) ([]Step, error) {
+	target := func(current, count int) (int, error) { return 0, nil }
+	return m.run(ctx, dryRun, target, (*migrate.Migrate).Down)
+}
+
+func (m *migrator) Steps(ctx context.Context, n int, dryRun bool) ([]Step, error) {
+	target := func(current, count int) (int, error) {
+		if current+n < 0 || current+n > count {
+			return 0, fmt.Errorf("can not apply %d steps, %d of %d migrations have been applied", n, current, count)
+		}
+
+		return current + n, nil
+	}
+
+	return m.run(ctx, dryRun, target, func(migration *migrate.Migrate) error { return migration.Steps(n) })
+}
+
+func (m *migrator) Goto(ctx context.Context, version uint, dryRun bool) ([]Step, error) {
+	versions, err := m.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	position := -1
+	for i, migration := range versions {
+		if migration.Version == version {
+			position = i + 1
+		}
+	}
+
+	if position == -1 {
+		return nil, fmt.Errorf("there is no migration with version %d", version)
+	}
+
+	target := func(current, count int) (int, error) { return position, nil }
+	return m.run(ctx, dryRun, target, func(migration *migrate.Migrate) error { return migration.Migrate(version) })
+}
+
+func (m *migrator) Force(ctx context.Context, version int) error {
+	err := m.rdbms.Migrate(ctx, migrations, m.dir, func(migration *migrate.Migrate) error {
+		return migration.Force(version)
+	})
+	if err != nil {
+		m.logger.Error("Error forcing the migration version", zap.Int("version", version), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+const QueryMigrationVersion = `SELECT version, dirty FROM schema_migrations LIMIT 1;`
+
+func (m *migrator) Check(ctx context.Context) error {
+	versions, err := m.versions()
+	if err != nil {
+		return err
+	}
+
+	var expected int64
+	if len(versions) != 0 {
+		expected = int64(versions[len(versions)-1].Version)
+	}
+
+	var version int64
+	var dirty bool
+	if err := m.rdbms.Read(ctx, QueryMigrationVersion, []any{}, []any{&version, &dirty}); err != nil {
+		if errors.Is(err, rdbms.ErrReadNotFound) && expected == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("error reading the migration version: %w", err)
+	}
+
+	if dirty {
+		return ErrDirty
+	} else if version != expected {
+		return fmt.Errorf("database is at version %d, expected version %d", version, expected)
+	}
+
+	return nil
+}
+
+// run plans the steps from the current position, the number of applied
+// migrations, to the one returned by target and applies them with apply.
+func (m *migrator) run(
+	ctx context.Context, dryRun bool,
+	target func(current, count int) (int, error), apply func(*migrate.Migrate) error,
+) ([]Step, error) {
+	versions, err := m.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []Step
+	err = m.rdbms.Migrate(ctx, migrations, m.dir, func(migration *migrate.Migrate) error {
+		version, dirty, err := migration.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		} else if dirty {
+			return ErrDirty
+		}
+
+		current := 0
+		for _, migration := range versions {
+			if migration.Version <= version {
+				current++
+			}
+		}
+
+		position, err := target(current, len(versions))
+		if err != nil {
+			return err
+		}
+
+		if steps, err = m.plan(versions, current, position); err != nil {
+			return err
+		}
+
+		// a database without any migration has no data to check yet
+		for _, step := range steps {
+			if preflight, ok := preflights[step.Version]; ok && step.Direction == DirectionUp && current != 0 {
+				if err := preflight(ctx, m.rdbms); err != nil {
+					return fmt.Errorf("migration %d can not be applied: %w", step.Version, err)
+				}
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		if err := apply(migration); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		m.logger.Error("Error running the migrations", zap.Bool("dry_run", dryRun), zap.Error(err))
+		return nil, err
+	}
+
+	return steps, nil
+}
+
+// preflights check the data which an up migration can not be applied to, a
+// failed migration would leave the database dirty instead of reporting it.
+var preflights = map[uint]func(ctx context.Context, executor rdbms.Executor) error{
+	6: duplicateEmails,
+}
+
+const QueryDuplicateEmails = `SELECT email, COUNT(*) FROM users GROUP BY email HAVING COUNT(*) > 1 ORDER BY email;`
+
+// duplicateEmails fails with the emails used by more than one user, which the
+// unique index of the migration 6 rejects.
+func duplicateEmails(ctx context.Context, executor rdbms.Executor) error {
+	var duplicates []string
+	err := executor.Iterate(ctx, QueryDuplicateEmails, []any{}, func(row rdbms.Row) error {
+		var email string
+		var count int
+		if err := row.Scan(&email, &count); err != nil {
+			return err
+		}
+
+		duplicates = append(duplicates, fmt.Sprintf("%s (%d users)", email, count))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading the duplicate emails: %w", err)
+	}
+
+	if len(duplicates) != 0 {
+		return fmt.Errorf(
+			"the emails %s are used by more than one user, delete or rename the duplicate users "+
+				"so every email has one user and migrate again", strings.Join(duplicates, ", "),
+		)
+	}
+
+	return nil
+}
+
+// plan returns the up steps from the current position to the target, or the down steps when the target is lower
+func (m *migrator) plan(versions []Migration, current, target int) ([]Step, error) {
+	files, err := iofs.New(migrations, m.dir)
+	if err != nil {
+		return nil, err
+	}
+	defer files.Close()
+
+	steps := make([]Step, 0)
+	for i := current; i < target; i++ {
+		step, err := readStep(files, versions[i], DirectionUp)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	for i := current - 1; i >= target; i-- {
+		step, err := readStep(files, versions[i], DirectionDown)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+func readStep(files source.Driver, migration Migration, direction string) (Step, error) {
+	read := files.ReadUp
+	if direction == DirectionDown {
+		read = files.ReadDown
+	}
+
+	reader, _, err := read(migration.Version)
+	if err != nil {
+		return Step{}, fmt.Errorf("error reading %s migration of version %d: %w", direction, migration.Version, err)
+	}
+	defer reader.Close()
+
+	query, err := io.ReadAll(reader)
+	if err != nil {
+		return Step{}, err
+	}
+
+	return Step{Version: migration.Version, Name: migration.Name, Direction: direction, Query: string(query)}, nil
+}
+
+// versions returns the embedded migrations of the dialect in order
+func (m *migrator) versions() ([]Migration, error) {
+	files, err := iofs.New(migrations, m.dir)
+	if err != nil {
+		return nil, err
+	}
+	defer files.Close()
+
+	var result []Migration
+	version, err := files.First()
+	for err == nil {
+		reader, name, readErr := files.ReadUp(version)
+		if readErr != nil {
+			return nil, readErr
+		}
+		reader.Close()
+
+		result = append(result, Migration{Version: version, Name: name})
+		version, err = files.Next(version)
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// CreateMigration adds empty up and down files with the next version to the
+// directory of every dialect in the directory of migrations, the directory is
+// the source tree since the embedded migrations are read-only.
+func CreateMigration(directory, name string) ([]string, error) {
+	name = strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if len(name) == 0 {
+		return nil, errors.New("invalid migration name has been given")
+	}
+
+	dialects, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var next uint64 = 1
+	for _, dialect := range dialects {
+		files, err := os.ReadDir(filepath.Join(directory, dialect.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			prefix, _, _ := strings.Cut(file.Name(), "_")
+			if version, err := strconv.ParseUint(prefix, 10, 64); err == nil && version >= next {
+				next = version + 1
+			}
+		}
+	}
+
+	var created []string
+	for _, dialect := range dialects {
+		if !dialect.IsDir() {
+			continue
+		}
+
+		for _, direction := range []string{DirectionUp, DirectionDown} {
+			file := filepath.Join(directory, dialect.Name(), fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+			if err := os.WriteFile(file, nil, 0o644); err != nil {
+				return created, err
+			}
+
+			created = append(created, file)
+		}
+	}
+
+	return created, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/CafeKetab/user/internal/models"
 	"github.com/CafeKetab/user/pkg/rdbms"
@@ -11,55 +12,110 @@ import (
 )
 
 type Repository interface {
-	MigrateUp(context.Context) error
-
-	MigrateDown(context.Context) error
+	// WithTx runs fn with a repository whose methods share a single transaction,
+	// fn may be retried on serialization failures. Nested calls reuse the
+	// transaction of the outer one.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
 
+	// CreateUser returns ErrEmailTaken when a user with the same email exists
 	CreateUser(ctx context.Context, user *models.User) error
 
+	// FindUserById returns ErrUserNotFound when there is no such user
 	FindUserById(ctx context.Context, id uint64) (*models.User, error)
 
-	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// FindUsersByIds returns the existing users of the given ids, the missing ids are skipped
+	FindUsersByIds(ctx context.Context, ids []uint64) ([]*models.User, error)
 
-	FindUserByEmailAndPassword(ctx context.Context, email, password string) (*models.User, error)
+	// FindUserByEmail returns ErrUserNotFound when there is no such user
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
 
 	// UpdateUser will only updates the first_name and last_name or password
 	UpdateUser(ctx context.Context, user *models.User) error
 
+	// UpdatePassword stores the given password hash and clears the reset requirement
+	UpdatePassword(ctx context.Context, id uint64, password string) error
+
+	// FlagLegacyPasswords requires a password reset for every user whose
+	// password is not stored as a known hash
+	FlagLegacyPasswords(ctx context.Context) error
+
+	MarkEmailVerified(ctx context.Context, id uint64) error
+
+	CreateToken(ctx context.Context, token *models.Token) error
+
+	// FindToken returns the unused and unexpired token with the given hash
+	// and purpose, it returns ErrTokenNotFound for any other token.
+	FindToken(ctx context.Context, hash, purpose string) (*models.Token, error)
+
+	// ConsumeToken marks the unused and unexpired token with the given hash
+	// and purpose as used, it returns ErrTokenNotFound for any other token.
+	ConsumeToken(ctx context.Context, hash, purpose string) (*models.Token, error)
+
+	// DeleteTokens removes every token of the user with the given purpose
+	DeleteTokens(ctx context.Context, userId uint64, purpose string) error
+
+	// UpdateTOTP stores the encrypted totp secret and whether the two-factor authentication is enabled
+	UpdateTOTP(ctx context.Context, id uint64, secret string, enabled bool) error
+
+	// UseTOTPStep records the time step of an accepted code, it returns
+	// ErrTOTPStepUsed when the step or a later one has already been used.
+	UseTOTPStep(ctx context.Context, id uint64, step int64) error
+
+	// ReplaceRecoveryCodes removes the previous recovery codes of the user and stores the new hashes
+	ReplaceRecoveryCodes(ctx context.Context, userId uint64, hashes []string) error
+
+	// ConsumeRecoveryCode marks the unused code as used, it returns
+	// ErrRecoveryCodeNotFound when the user has no such unused code.
+	ConsumeRecoveryCode(ctx context.Context, userId uint64, hash string) error
+
+	DeleteRecoveryCodes(ctx context.Context, userId uint64) error
+
 	DeleteUser(ctx context.Context, user *models.User) error
 }
 
 type repository struct {
-	logger             *zap.Logger
-	rdbms              rdbms.RDBMS
-	migrationDirectory string
+	logger *zap.Logger
+	db     rdbms.RDBMS
+	// rdbms is either the db or the transaction of WithTx
+	rdbms rdbms.Executor
+	inTx  bool
 }
 
 func New(lg *zap.Logger, rdbms rdbms.RDBMS) Repository {
-	r := &repository{logger: lg, rdbms: rdbms}
-	r.migrationDirectory = "file://internal/repository/migrations"
-
-	return r
+	return &repository{logger: lg, db: rdbms, rdbms: rdbms}
 }
 
-func (r *repository) MigrateUp(ctx context.Context) error {
-	return r.rdbms.MigrateUp(r.migrationDirectory)
+func (r *repository) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return r.withTx(ctx, func(tx *repository) error { return fn(tx) })
 }
 
-func (r *repository) MigrateDown(ctx context.Context) error {
-	return r.rdbms.MigrateDown(r.migrationDirectory)
+func (r *repository) withTx(ctx context.Context, fn func(tx *repository) error) error {
+	if r.inTx {
+		return fn(r)
+	}
+
+	return r.db.WithTx(ctx, func(tx rdbms.Executor) error {
+		return fn(&repository{logger: r.logger, db: r.db, rdbms: tx, inTx: true})
+	})
 }
 
 const QueryCreateUser = `INSERT INTO users(first_name, last_name, email, password) VALUES($1, $2, $3, $4) RETURNING id;`
 
-func (r *repository) CreateUser(ctx context.Context, user *models.User) error {
+func (r *repository) CreateUser(ctx context.Context, user *models.User) (err error) {
+	ctx, done := r.observe(ctx, "CreateUser")
+	defer done(&err)
+
 	if len(user.Email) == 0 || len(user.Password) == 0 {
 		return errors.New("Insufficient information for user")
 	}
 
 	args := []interface{}{user.FirstName, user.LastName, user.Email, user.Password}
-	id, err := r.rdbms.Create(QueryCreateUser, args)
+	id, err := r.rdbms.Create(ctx, QueryCreateUser, args)
 	if err != nil {
+		if errors.Is(err, rdbms.ErrDuplicate) {
+			return ErrEmailTaken
+		}
+
 		r.logger.Error("Error creating user", zap.Error(err))
 		return err
 	}
@@ -68,14 +124,29 @@ func (r *repository) CreateUser(ctx context.Context, user *models.User) error {
 	return nil
 }
 
-const QueryFindUserById = "SELECT first_name, last_name, email, password, created_at FROM users WHERE id=$1;"
+const QueryFindUserById = `
+	SELECT first_name, last_name, email, password, password_reset_required, created_at, email_verified_at,
+		totp_secret, totp_enabled
+	FROM users
+	WHERE id=$1;`
+
+func (r *repository) FindUserById(ctx context.Context, id uint64) (_ *models.User, err error) {
+	ctx, done := r.observe(ctx, "FindUserById")
+	defer done(&err)
 
-func (r *repository) FindUserById(ctx context.Context, id uint64) (*models.User, error) {
 	user := &models.User{Id: id}
 
 	args := []interface{}{id}
-	dest := []interface{}{&user.FirstName, &user.LastName, &user.Email, &user.Password, &user.CreatedAt}
-	if err := r.rdbms.Read(QueryFindUserById, args, dest); err != nil {
+	dest := []interface{}{
+		&user.FirstName, &user.LastName, &user.Email, &user.Password,
+		&user.PasswordResetRequired, &user.CreatedAt, &user.EmailVerifiedAt,
+		&user.TOTPSecret, &user.TwoFactorEnabled,
+	}
+	if err := r.rdbms.Read(ctx, QueryFindUserById, args, dest); err != nil {
+		if errors.Is(err, rdbms.ErrReadNotFound) {
+			return nil, ErrUserNotFound
+		}
+
 		r.logger.Error("Error find user by id", zap.Error(err))
 		return nil, err
 	}
@@ -83,19 +154,47 @@ func (r *repository) FindUserById(ctx context.Context, id uint64) (*models.User,
 	return user, nil
 }
 
+const QueryFindUsersByIds = `
+	SELECT id, first_name, last_name, email, password, password_reset_required, created_at, email_verified_at,
+		totp_secret, totp_enabled
+	FROM users
+	WHERE id IN ($1)
+	ORDER BY id;`
+
+func (r *repository) FindUsersByIds(ctx context.Context, ids []uint64) (_ []*models.User, err error) {
+	ctx, done := r.observe(ctx, "FindUsersByIds")
+	defer done(&err)
+
+	users := make([]*models.User, 0, len(ids))
+	if err := r.rdbms.Query(ctx, QueryFindUsersByIds, []interface{}{ids}, &users); err != nil {
+		r.logger.Error("Error find users by ids", zap.Int("count", len(ids)), zap.Error(err))
+		return nil, err
+	}
+
+	return users, nil
+}
+
 const QueryFindUserByEmail = `
-	SELECT id, first_name, last_name, password, created_at
+	SELECT id, first_name, last_name, password, password_reset_required, created_at, email_verified_at,
+		totp_secret, totp_enabled
 	FROM users
 	WHERE email=$1;`
 
-func (r *repository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+func (r *repository) FindUserByEmail(ctx context.Context, email string) (_ *models.User, err error) {
+	ctx, done := r.observe(ctx, "FindUserByEmail")
+	defer done(&err)
+
 	user := &models.User{Email: email}
 
 	args := []interface{}{email}
-	dest := []interface{}{&user.Id, &user.FirstName, &user.LastName, &user.Password, &user.CreatedAt}
-	if err := r.rdbms.Read(QueryFindUserByEmail, args, dest); err != nil {
-		if err.Error() == rdbms.ErrReadNotFound {
-			return nil, err
+	dest := []interface{}{
+		&user.Id, &user.FirstName, &user.LastName, &user.Password,
+		&user.PasswordResetRequired, &user.CreatedAt, &user.EmailVerifiedAt,
+		&user.TOTPSecret, &user.TwoFactorEnabled,
+	}
+	if err := r.rdbms.Read(ctx, QueryFindUserByEmail, args, dest); err != nil {
+		if errors.Is(err, rdbms.ErrReadNotFound) {
+			return nil, ErrUserNotFound
 		}
 
 		r.logger.Error("Error find user by email", zap.Error(err))
@@ -105,27 +204,62 @@ func (r *repository) FindUserByEmail(ctx context.Context, email string) (*models
 	return user, nil
 }
 
-const QueryFindUserByEmailAndPassword = "SELECT id, first_name, last_name, created_at FROM users WHERE email=$1 AND password=$2;"
+const QueryUpdateUser = "UPDATE users SET first_name=$1, last_name=$2, password=$3 WHERE id=$4;"
 
-func (r *repository) FindUserByEmailAndPassword(ctx context.Context, email, password string) (*models.User, error) {
-	user := &models.User{Email: email, Password: password}
+func (r *repository) UpdateUser(ctx context.Context, user *models.User) (err error) {
+	ctx, done := r.observe(ctx, "UpdateUser")
+	defer done(&err)
 
-	args := []interface{}{email, password}
-	dest := []interface{}{&user.Id, &user.FirstName, &user.LastName, &user.CreatedAt}
-	if err := r.rdbms.Read(QueryFindUserByEmailAndPassword, args, dest); err != nil {
-		r.logger.Error("Error find user by email and password", zap.Error(err))
-		return nil, err
+	args := []interface{}{user.FirstName, user.LastName, user.Password, user.Id}
+	if err := r.rdbms.Update(ctx, QueryUpdateUser, args); err != nil {
+		r.logger.Error("Error updating user", zap.Any("user", user), zap.Error(err))
+		return err
 	}
 
-	return user, nil
+	return nil
 }
 
-const QueryUpdateUser = "UPDATE users SET first_name=?, last_name=?, password=? WHERE id=$1;"
+const QueryUpdatePassword = "UPDATE users SET password=$1, password_reset_required=FALSE WHERE id=$2;"
 
-func (r *repository) UpdateUser(ctx context.Context, user *models.User) error {
-	args := []interface{}{user.FirstName, user.LastName, user.Password, user.Id}
-	if err := r.rdbms.Update(QueryUpdateUser, args); err != nil {
-		r.logger.Error("Error updating user", zap.Any("user", user), zap.Error(err))
+func (r *repository) UpdatePassword(ctx context.Context, id uint64, password string) (err error) {
+	ctx, done := r.observe(ctx, "UpdatePassword")
+	defer done(&err)
+
+	args := []interface{}{password, id}
+	if err := r.rdbms.Update(ctx, QueryUpdatePassword, args); err != nil {
+		r.logger.Error("Error updating user password", zap.Uint64("id", id), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Argon2id hashes start with $argon2id$ and bcrypt hashes with $2a$, $2b$ or $2y$
+const QueryFlagLegacyPasswords = `
+	UPDATE users SET password_reset_required=TRUE
+	WHERE password NOT LIKE '$argon2id$%' AND password NOT LIKE '$2_$%';`
+
+func (r *repository) FlagLegacyPasswords(ctx context.Context) (err error) {
+	ctx, done := r.observe(ctx, "FlagLegacyPasswords")
+	defer done(&err)
+
+	if err := r.rdbms.Update(ctx, QueryFlagLegacyPasswords, []interface{}{}); err != nil {
+		r.logger.Error("Error flagging legacy passwords", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+const QueryMarkEmailVerified = "UPDATE users SET email_verified_at=$1 WHERE id=$2;"
+
+func (r *repository) MarkEmailVerified(ctx context.Context, id uint64) (err error) {
+	ctx, done := r.observe(ctx, "MarkEmailVerified")
+	defer done(&err)
+
+	args := []interface{}{time.Now().UTC(), id}
+	if err := r.rdbms.Update(ctx, QueryMarkEmailVerified, args); err != nil {
+		r.logger.Error("Error marking email as verified", zap.Uint64("id", id), zap.Error(err))
 		return err
 	}
 
@@ -134,9 +268,12 @@ func (r *repository) UpdateUser(ctx context.Context, user *models.User) error {
 
 const QueryDeleteUser = "DELETE FROM users WHERE id=$1;"
 
-func (r *repository) DeleteUser(ctx context.Context, user *models.User) error {
+func (r *repository) DeleteUser(ctx context.Context, user *models.User) (err error) {
+	ctx, done := r.observe(ctx, "DeleteUser")
+	defer done(&err)
+
 	args := []interface{}{user.Id}
-	if err := r.rdbms.Delete(QueryDeleteUser, args); err != nil {
+	if err := r.rdbms.Delete(ctx, QueryDeleteUser, args); err != nil {
 		r.logger.Error("Error deleting user", zap.Any("user", user), zap.Error(err))
 		return err
 	}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CafeKetab/user/internal/models"
+	"github.com/CafeKetab/user/pkg/rdbms"
+	"go.uber.org/zap"
+)
+
+// The timestamps are always passed in UTC from the application, since the
+// columns are stored without time zone.
+
+const QueryCreateToken = `
+	INSERT INTO verification_tokens(user_id, token_hash, purpose, expires_at)
+	VALUES($1, $2, $3, $4) RETURNING id;`
+
+func (r *repository) CreateToken(ctx context.Context, token *models.Token) (err error) {
+	ctx, done := r.observe(ctx, "CreateToken")
+	defer done(&err)
+
+	args := []interface{}{token.UserId, token.Hash, token.Purpose, token.ExpiresAt.UTC()}
+	id, err := r.rdbms.Create(ctx, QueryCreateToken, args)
+	if err != nil {
+		if errors.Is(err, rdbms.ErrForeignKey) {
+			return ErrUserNotFound
+		}
+
+		r.logger.Error("Error creating token", zap.Uint64("user_id", token.UserId), zap.Error(err))
+		return err
+	}
+
+	token.Id = id
+	return nil
+}
+
+const QueryFindToken = `
+	SELECT id, user_id, expires_at
+	FROM verification_tokens
+	WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > $3;`
+
+func (r *repository) FindToken(ctx context.Context, hash, purpose string) (_ *models.Token, err error) {
+	ctx, done := r.observe(ctx, "FindToken")
+	defer done(&err)
+
+	token := &models.Token{Hash: hash, Purpose: purpose}
+
+	args := []interface{}{hash, purpose, time.Now().UTC()}
+	dest := []interface{}{&token.Id, &token.UserId, &token.ExpiresAt}
+	if err := r.rdbms.Read(ctx, QueryFindToken, args, dest); err != nil {
+		if errors.Is(err, rdbms.ErrReadNotFound) {
+			return nil, ErrTokenNotFound
+		}
+
+		r.logger.Error("Error finding token", zap.String("purpose", purpose), zap.Error(err))
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// the token is locked until the end of the transaction, so only one of the
+// concurrent consumers finds it unused
+const QueryLockToken = `
+	SELECT id, user_id, expires_at
+	FROM verification_tokens
+	WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > $3
+	FOR UPDATE;`
+
+const QueryUseToken = "UPDATE verification_tokens SET used_at=$1 WHERE id=$2;"
+
+func (r *repository) ConsumeToken(ctx context.Context, hash, purpose string) (_ *models.Token, err error) {
+	ctx, done := r.observe(ctx, "ConsumeToken")
+	defer done(&err)
+
+	now := time.Now().UTC()
+	token := &models.Token{Hash: hash, Purpose: purpose, UsedAt: &now}
+
+	err = r.withTx(ctx, func(tx *repository) error {
+		args := []interface{}{hash, purpose, now}
+		dest := []interface{}{&token.Id, &token.UserId, &token.ExpiresAt}
+		if err := tx.rdbms.Read(ctx, QueryLockToken, args, dest); err != nil {
+			return err
+		}
+
+		return tx.rdbms.Update(ctx, QueryUseToken, []interface{}{now, token.Id})
+	})
+	if err != nil {
+		if errors.Is(err, rdbms.ErrReadNotFound) {
+			return nil, ErrTokenNotFound
+		}
+
+		r.logger.Error("Error consuming token", zap.String("purpose", purpose), zap.Error(err))
+		return nil, err
+	}
+
+	return token, nil
+}
+
+const QueryDeleteTokens = "DELETE FROM verification_tokens WHERE user_id=$1 AND purpose=$2;"
+
+func (r *repository) DeleteTokens(ctx context.Context, userId uint64, purpose string) (err error) {
+	ctx, done := r.observe(ctx, "DeleteTokens")
+	defer done(&err)
+
+	args := []interface{}{userId, purpose}
+	if err := r.rdbms.Delete(ctx, QueryDeleteTokens, args); err != nil {
+		r.logger.Error("Error deleting tokens", zap.Uint64("user_id", userId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CafeKetab/user/pkg/rdbms"
+	"go.uber.org/zap"
+)
+
+const QueryUpdateTOTP = "UPDATE users SET totp_secret=$1, totp_enabled=$2, totp_last_step=0 WHERE id=$3;"
+
+func (r *repository) UpdateTOTP(ctx context.Context, id uint64, secret string, enabled bool) (err error) {
+	ctx, done := r.observe(ctx, "UpdateTOTP")
+	defer done(&err)
+
+	args := []interface{}{secret, enabled, id}
+	if err := r.rdbms.Update(ctx, QueryUpdateTOTP, args); err != nil {
+		r.logger.Error("Error updating totp of user", zap.Uint64("id", id), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+const QueryLockTOTPStep = "SELECT totp_last_step FROM users WHERE id=$1 FOR UPDATE;"
+
+const QueryUseTOTPStep = "UPDATE users SET totp_last_step=$1 WHERE id=$2;"
+
+func (r *repository) UseTOTPStep(ctx context.Context, id uint64, step int64) (err error) {
+	ctx, done := r.observe(ctx, "UseTOTPStep")
+	defer done(&err)
+
+	err = r.withTx(ctx, func(tx *repository) error {
+		var lastStep int64
+		if err := tx.rdbms.Read(ctx, QueryLockTOTPStep, []interface{}{id}, []interface{}{&lastStep}); err != nil {
+			return err
+		}
+
+		if step <= lastStep {
+			return ErrTOTPStepUsed
+		}
+
+		return tx.rdbms.Update(ctx, QueryUseTOTPStep, []interface{}{step, id})
+	})
+	if err != nil {
+		if errors.Is(err, ErrTOTPStepUsed) || errors.Is(err, rdbms.ErrReadNotFound) {
+			return ErrTOTPStepUsed
+		}
+
+		r.logger.Error("Error using totp step", zap.Uint64("id", id), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+const QueryCreateRecoveryCode = "INSERT INTO recovery_codes(user_id, code_hash) VALUES($1, $2) RETURNING id;"
+
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userId uint64, hashes []string) (err error) {
+	ctx, done := r.observe(ctx, "ReplaceRecoveryCodes")
+	defer done(&err)
+
+	return r.withTx(ctx, func(tx *repository) error {
+		if err := tx.DeleteRecoveryCodes(ctx, userId); err != nil {
+			return err
+		}
+
+		return tx.createRecoveryCodes(ctx, userId, hashes)
+	})
+}
+
+func (r *repository) createRecoveryCodes(ctx context.Context, userId uint64, hashes []string) error {
+	for _, hash := range hashes {
+		args := []interface{}{userId, hash}
+		if _, err := r.rdbms.Create(ctx, QueryCreateRecoveryCode, args); err != nil {
+			r.logger.Error("Error creating recovery code", zap.Uint64("user_id", userId), zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+const QueryLockRecoveryCode = `
+	SELECT id
+	FROM recovery_codes
+	WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL
+	FOR UPDATE;`
+
+const QueryUseRecoveryCode = "UPDATE recovery_codes SET used_at=$1 WHERE id=$2;"
+
+func (r *repository) ConsumeRecoveryCode(ctx context.Context, userId uint64, hash string) (err error) {
+	ctx, done := r.observe(ctx, "ConsumeRecoveryCode")
+	defer done(&err)
+
+	err = r.withTx(ctx, func(tx *repository) error {
+		var id uint64
+		if err := tx.rdbms.Read(ctx, QueryLockRecoveryCode, []interface{}{userId, hash}, []interface{}{&id}); err != nil {
+			return err
+		}
+
+		return tx.rdbms.Update(ctx, QueryUseRecoveryCode, []interface{}{time.Now().UTC(), id})
+	})
+	if err != nil {
+		if errors.Is(err, rdbms.ErrReadNotFound) {
+			return ErrRecoveryCodeNotFound
+		}
+
+		r.logger.Error("Error consuming recovery code", zap.Uint64("user_id", userId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+const QueryDeleteRecoveryCodes = "DELETE FROM recovery_codes WHERE user_id=$1;"
+
+func (r *repository) DeleteRecoveryCodes(ctx context.Context, userId uint64) (err error) {
+	ctx, done := r.observe(ctx, "DeleteRecoveryCodes")
+	defer done(&err)
+
+	args := []interface{}{userId}
+	if err := r.rdbms.Delete(ctx, QueryDeleteRecoveryCodes, args); err != nil {
+		r.logger.Error("Error deleting recovery codes", zap.Uint64("user_id", userId), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
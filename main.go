@@ -14,6 +14,7 @@ import (
 func main() {
 	const description = "user microservice for CafeKetab"
 	root := &cobra.Command{Short: description}
+	root.PersistentFlags().StringP(cmd.ConfigFlag, "c", "", "path of the YAML or TOML configuration file")
 
 	trap := make(chan os.Signal, 1)
 	signal.Notify(trap, syscall.SIGINT, syscall.SIGTERM)
@@ -21,6 +22,8 @@ func main() {
 	root.AddCommand(
 		cmd.Server{}.Command(trap),
 		cmd.Migrate{}.Command(trap),
+		cmd.Passwords{}.Command(trap),
+		cmd.Config{}.Command(),
 	)
 
 	if err := root.Execute(); err != nil {
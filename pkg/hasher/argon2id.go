@@ -0,0 +1,132 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// Bounds of the parameters of the config and of the stored hashes, a hash out
+// of them is rejected instead of exhausting the memory or the cpu, or panicking
+// on zero parallelism. An empty key would match any password.
+const (
+	Argon2idMaxMemory     = 1024 * 1024 // KiB
+	Argon2idMaxIterations = 64
+	Argon2idMinSaltLength = 8
+	Argon2idMinKeyLength  = 16
+	Argon2idMaxLength     = 1024
+)
+
+type argon2idHasher struct {
+	config *Argon2idConfig
+}
+
+func newArgon2id(cfg *Argon2idConfig) *argon2idHasher {
+	return &argon2idHasher{config: cfg}
+}
+
+func (h *argon2idHasher) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+// Hash returns the hash in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.config.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error generating salt: %w", err)
+	}
+
+	key := argon2.IDKey(
+		[]byte(password), salt,
+		h.config.Iterations, h.config.Memory, h.config.Parallelism, h.config.KeyLength,
+	)
+
+	encoded := fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		h.config.Memory, h.config.Iterations, h.config.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return encoded, nil
+}
+
+func (h *argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey(
+		[]byte(password), salt,
+		params.Iterations, params.Memory, params.Parallelism, params.KeyLength,
+	)
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, _, err := h.decode(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.config.Memory ||
+		params.Iterations != h.config.Iterations ||
+		params.Parallelism != h.config.Parallelism ||
+		params.KeyLength != h.config.KeyLength ||
+		uint32(len(salt)) != h.config.SaltLength
+}
+
+func (h *argon2idHasher) decode(encoded string) (*Argon2idConfig, []byte, []byte, error) {
+	// ["", "argon2id", "v=19", "m=65536,t=3,p=2", "<salt>", "<key>"]
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	params := &Argon2idConfig{}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	params.KeyLength = uint32(len(key))
+
+	if !validArgon2idParams(params) {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
+
+func validArgon2idParams(params *Argon2idConfig) bool {
+	return params.Memory > 0 && params.Memory <= Argon2idMaxMemory &&
+		params.Iterations > 0 && params.Iterations <= Argon2idMaxIterations &&
+		params.Parallelism > 0 &&
+		params.SaltLength >= Argon2idMinSaltLength && params.SaltLength <= Argon2idMaxLength &&
+		params.KeyLength >= Argon2idMinKeyLength && params.KeyLength <= Argon2idMaxLength
+}
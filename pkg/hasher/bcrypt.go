@@ -0,0 +1,53 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type bcryptHasher struct {
+	config *BcryptConfig
+}
+
+func newBcrypt(cfg *BcryptConfig) *bcryptHasher {
+	return &bcryptHasher{config: cfg}
+}
+
+func (h *bcryptHasher) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+// Hash returns the hash in the modular crypt format: $2a$<cost>$<salt><key>
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	encoded, err := bcrypt.GenerateFromPassword([]byte(password), h.config.Cost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password with bcrypt: %w", err)
+	}
+
+	return string(encoded), nil
+}
+
+func (h *bcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == nil {
+		return true, nil
+	} else if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	return false, ErrInvalidHash
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+
+	return cost != h.config.Cost
+}
@@ -0,0 +1,19 @@
+package hasher
+
+type Config struct {
+	Algorithm string          `koanf:"algorithm"`
+	Argon2id  *Argon2idConfig `koanf:"argon2id"`
+	Bcrypt    *BcryptConfig   `koanf:"bcrypt"`
+}
+
+type Argon2idConfig struct {
+	Memory      uint32 `koanf:"memory"`
+	Iterations  uint32 `koanf:"iterations"`
+	Parallelism uint8  `koanf:"parallelism"`
+	SaltLength  uint32 `koanf:"salt_length"`
+	KeyLength   uint32 `koanf:"key_length"`
+}
+
+type BcryptConfig struct {
+	Cost int `koanf:"cost"`
+}
@@ -0,0 +1,101 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+	ErrInvalidHash      = errors.New("encoded password hash is not in a valid format")
+)
+
+// Hasher hashes passwords into PHC formatted strings, which carry the algorithm
+// and its parameters, so hashes created by older configurations stay verifiable.
+type Hasher interface {
+	Hash(password string) (string, error)
+
+	Verify(password, encoded string) (bool, error)
+
+	// NeedsRehash reports whether the encoded hash was created by another
+	// algorithm or with parameters different from the current configuration.
+	NeedsRehash(encoded string) bool
+
+	// VerifyDummy verifies the password against a fixed hash of the current
+	// configuration, the logins of unknown emails call it so they take as long
+	// as the ones of the registered emails.
+	VerifyDummy(password string)
+}
+
+// algorithm is implemented by every supported hashing algorithm
+type algorithm interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+
+	// Identify reports whether the encoded hash belongs to this algorithm
+	Identify(encoded string) bool
+}
+
+type hasher struct {
+	current    algorithm
+	algorithms []algorithm
+	dummy      string
+}
+
+const dummyPassword = "dummy password of the unknown users"
+
+func New(cfg *Config) (Hasher, error) {
+	h := &hasher{
+		algorithms: []algorithm{newArgon2id(cfg.Argon2id), newBcrypt(cfg.Bcrypt)},
+	}
+
+	switch strings.ToLower(cfg.Algorithm) {
+	case Argon2id:
+		h.current = h.algorithms[0]
+	case Bcrypt:
+		h.current = h.algorithms[1]
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+
+	dummy, err := h.current.Hash(dummyPassword)
+	if err != nil {
+		return nil, fmt.Errorf("Error hashing the dummy password\n%v", err)
+	}
+	h.dummy = dummy
+
+	return h, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *hasher) Verify(password, encoded string) (bool, error) {
+	for _, algorithm := range h.algorithms {
+		if algorithm.Identify(encoded) {
+			return algorithm.Verify(password, encoded)
+		}
+	}
+
+	return false, ErrInvalidHash
+}
+
+func (h *hasher) VerifyDummy(password string) {
+	_, _ = h.current.Verify(password, h.dummy)
+}
+
+func (h *hasher) NeedsRehash(encoded string) bool {
+	if !h.current.Identify(encoded) {
+		return true
+	}
+
+	return h.current.NeedsRehash(encoded)
+}
@@ -0,0 +1,179 @@
+package hasher
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testConfig has the cheapest parameters, so the tests stay fast
+func testConfig(algorithm string) *Config {
+	return &Config{
+		Algorithm: algorithm,
+		Argon2id:  &Argon2idConfig{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		Bcrypt:    &BcryptConfig{Cost: 4},
+	}
+}
+
+func newHasher(t *testing.T, algorithm string) Hasher {
+	t.Helper()
+
+	h, err := New(testConfig(algorithm))
+	if err != nil {
+		t.Fatalf("creating hasher: %v", err)
+	}
+
+	return h
+}
+
+func hash(t *testing.T, h Hasher, password string) string {
+	t.Helper()
+
+	encoded, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("hashing: %v", err)
+	}
+
+	return encoded
+}
+
+func TestUnknownAlgorithm(t *testing.T) {
+	if _, err := New(testConfig("md5")); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected %v, got %v", ErrUnknownAlgorithm, err)
+	}
+}
+
+func TestArgon2idEncoding(t *testing.T) {
+	encoded := hash(t, newHasher(t, Argon2id), "password")
+
+	prefix := "$argon2id$v=19$m=64,t=1,p=1$"
+	if !strings.HasPrefix(encoded, prefix) {
+		t.Fatalf("expected the prefix %q, got %q", prefix, encoded)
+	}
+
+	params, salt, key, err := newArgon2id(testConfig(Argon2id).Argon2id).decode(encoded)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+
+	if params.Memory != 64 || params.Iterations != 1 || params.Parallelism != 1 || len(salt) != 16 || len(key) != 32 {
+		t.Errorf("unexpected decoded params %+v, salt of %d and key of %d bytes", params, len(salt), len(key))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	argon2id, bcrypt := newHasher(t, Argon2id), newHasher(t, Bcrypt)
+
+	tests := []struct {
+		name     string
+		hasher   Hasher
+		encoded  string
+		password string
+		ok       bool
+	}{
+		{name: "argon2id", hasher: argon2id, encoded: hash(t, argon2id, "password"), password: "password", ok: true},
+		{name: "argon2id wrong password", hasher: argon2id, encoded: hash(t, argon2id, "password"), password: "Password"},
+		{name: "bcrypt", hasher: bcrypt, encoded: hash(t, bcrypt, "password"), password: "password", ok: true},
+		{name: "bcrypt wrong password", hasher: bcrypt, encoded: hash(t, bcrypt, "password"), password: "Password"},
+		{name: "bcrypt hash with argon2id current", hasher: argon2id, encoded: hash(t, bcrypt, "password"), password: "password", ok: true},
+		{name: "argon2id hash with bcrypt current", hasher: bcrypt, encoded: hash(t, argon2id, "password"), password: "password", ok: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := test.hasher.Verify(test.password, test.encoded)
+			if err != nil {
+				t.Fatalf("verifying: %v", err)
+			} else if ok != test.ok {
+				t.Errorf("expected %v, got %v", test.ok, ok)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	argon2id := func(params string) string {
+		return fmt.Sprintf("$argon2id$v=19$%s$%s$%s", params, salt, key)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "empty", encoded: ""},
+		{name: "plaintext", encoded: "password"},
+		{name: "unknown algorithm", encoded: "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+		{name: "missing parts", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{name: "wrong version", encoded: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{name: "missing params", encoded: argon2id("m=64,t=1")},
+		{name: "zero parallelism", encoded: argon2id("m=64,t=1,p=0")},
+		{name: "overflowing parallelism", encoded: argon2id("m=64,t=1,p=256")},
+		{name: "zero iterations", encoded: argon2id("m=64,t=0,p=1")},
+		{name: "too many iterations", encoded: argon2id("m=64,t=100000,p=1")},
+		{name: "zero memory", encoded: argon2id("m=0,t=1,p=1")},
+		{name: "too much memory", encoded: argon2id("m=4294967295,t=1,p=1")},
+		{name: "invalid salt", encoded: "$argon2id$v=19$m=64,t=1,p=1$!!!$" + key},
+		{name: "short salt", encoded: "$argon2id$v=19$m=64,t=1,p=1$AAAA$" + key},
+		{name: "empty key", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$"},
+		{name: "short key", encoded: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$AAAA"},
+		{name: "truncated bcrypt", encoded: "$2a$04$abc"},
+	}
+
+	h := newHasher(t, Argon2id)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := h.Verify("password", test.encoded)
+			if !errors.Is(err, ErrInvalidHash) || ok {
+				t.Errorf("expected %v, got %v and %v", ErrInvalidHash, ok, err)
+			}
+
+			if !h.NeedsRehash(test.encoded) {
+				t.Errorf("expected a malformed hash to need a rehash")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2id, bcrypt := newHasher(t, Argon2id), newHasher(t, Bcrypt)
+
+	stronger := testConfig(Argon2id)
+	stronger.Argon2id.Iterations = 2
+	strongerArgon2id, err := New(stronger)
+	if err != nil {
+		t.Fatalf("creating hasher: %v", err)
+	}
+
+	costlier := testConfig(Bcrypt)
+	costlier.Bcrypt.Cost = 5
+	costlierBcrypt, err := New(costlier)
+	if err != nil {
+		t.Fatalf("creating hasher: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		hasher   Hasher
+		encoded  string
+		expected bool
+	}{
+		{name: "argon2id same params", hasher: argon2id, encoded: hash(t, argon2id, "password")},
+		{name: "argon2id other params", hasher: strongerArgon2id, encoded: hash(t, argon2id, "password"), expected: true},
+		{name: "bcrypt same cost", hasher: bcrypt, encoded: hash(t, bcrypt, "password")},
+		{name: "bcrypt other cost", hasher: costlierBcrypt, encoded: hash(t, bcrypt, "password"), expected: true},
+		{name: "bcrypt to argon2id", hasher: argon2id, encoded: hash(t, bcrypt, "password"), expected: true},
+		{name: "argon2id to bcrypt", hasher: bcrypt, encoded: hash(t, argon2id, "password"), expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if needs := test.hasher.NeedsRehash(test.encoded); needs != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, needs)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package health
+
+import "time"
+
+type Config struct {
+	// Timeout of every check, a check which takes longer is reported down
+	Timeout time.Duration `koanf:"timeout"`
+
+	// CacheTTL is how long a report is served before the checks run again
+	CacheTTL time.Duration `koanf:"cache_ttl"`
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDraining = "draining"
+)
+
+// Checker reports whether a dependency is usable, ctx carries the check timeout
+type Checker func(ctx context.Context) error
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status    string                  `json:"status"`
+	Checks    map[string]*CheckResult `json:"checks,omitempty"`
+	CheckedAt time.Time               `json:"checked_at"`
+}
+
+func (report *Report) Ready() bool {
+	return report.Status == StatusUp
+}
+
+type Health interface {
+	// Register adds a checker to the readiness, it must be called before the first Ready
+	Register(name string, checker Checker)
+
+	// Ready runs every checker concurrently and reports up when all of them
+	// succeed, the report is cached for the configured TTL. The checkers are
+	// skipped and draining is reported as soon as ready returns false.
+	Ready(ctx context.Context) *Report
+}
+
+type health struct {
+	config *Config
+	logger *zap.Logger
+	ready  func() bool
+
+	checkers map[string]Checker
+
+	mutex   sync.Mutex
+	cached  *Report
+	expires time.Time
+}
+
+// New creates the readiness of the service, ready gates every check, e.g. the
+// lifecycle manager which turns false once the shutdown begins.
+func New(cfg *Config, lg *zap.Logger, ready func() bool) Health {
+	return &health{config: cfg, logger: lg, ready: ready, checkers: make(map[string]Checker)}
+}
+
+func (h *health) Register(name string, checker Checker) {
+	h.checkers[name] = checker
+}
+
+func (h *health) Ready(ctx context.Context) *Report {
+	if !h.ready() {
+		return &Report{Status: StatusDraining, CheckedAt: time.Now()}
+	}
+
+	// concurrent probes wait for the running checks instead of starting their own
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.cached != nil && time.Now().Before(h.expires) {
+		return h.cached
+	}
+
+	h.cached = h.check(ctx)
+	h.expires = time.Now().Add(h.config.CacheTTL)
+
+	return h.cached
+}
+
+func (h *health) check(ctx context.Context) *Report {
+	report := &Report{Status: StatusUp, Checks: make(map[string]*CheckResult, len(h.checkers))}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range h.checkers {
+		wg.Add(1)
+
+		go func(name string, checker Checker) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checker(ctx)
+			result := &CheckResult{Status: StatusUp, Duration: time.Since(start).String()}
+			if err != nil {
+				h.logger.Warn("Error readiness check has failed", zap.String("check", name), zap.Error(err))
+				result.Status, result.Error = StatusDown, err.Error()
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = StatusDown
+			}
+		}(name, checker)
+	}
+
+	wg.Wait()
+	report.CheckedAt = time.Now()
+
+	return report
+}
@@ -0,0 +1,9 @@
+package lifecycle
+
+import "time"
+
+type Config struct {
+	// ShutdownTimeout is the deadline of running every stop hook, the servers
+	// are drained meanwhile and closed forcefully once it has passed.
+	ShutdownTimeout time.Duration `koanf:"shutdown_timeout"`
+}
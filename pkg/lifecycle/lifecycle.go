@@ -0,0 +1,158 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// Hook of a component, OnStart must not block, long running work like
+// serving is started with Manager.Go instead. Either function may be nil.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Manager starts the hooks in the order they are appended and stops them in
+// the reverse order, so a component is stopped before its dependencies.
+type Manager interface {
+	Append(hook Hook)
+
+	// Go runs fn in the background, the manager is stopped when it fails
+	Go(name string, fn func() error)
+
+	// Start runs the start hooks, when one fails the hooks started before it
+	// are stopped and its error is returned.
+	Start(ctx context.Context) error
+
+	// Wait blocks until a signal is received or a background function fails
+	Wait(trap <-chan os.Signal) error
+
+	// Stop marks the manager as not ready and runs the stop hooks within the
+	// shutdown timeout, every error is returned joined.
+	Stop() error
+
+	// Ready is true from a successful Start until Stop begins
+	Ready() bool
+}
+
+type manager struct {
+	config *Config
+	logger *zap.Logger
+
+	mutex   sync.Mutex
+	hooks   []Hook
+	started int
+
+	ready   atomic.Bool
+	running sync.WaitGroup
+	failed  chan error
+}
+
+func New(cfg *Config, lg *zap.Logger) Manager {
+	return &manager{config: cfg, logger: lg, failed: make(chan error, 1)}
+}
+
+func (m *manager) Append(hook Hook) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.hooks = append(m.hooks, hook)
+}
+
+func (m *manager) Go(name string, fn func() error) {
+	m.running.Add(1)
+
+	go func() {
+		defer m.running.Done()
+
+		if err := fn(); err != nil {
+			m.logger.Error("Error running in background", zap.String("name", name), zap.Error(err))
+
+			select {
+			case m.failed <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}
+	}()
+}
+
+func (m *manager) Start(ctx context.Context) error {
+	m.mutex.Lock()
+	hooks := m.hooks
+	m.mutex.Unlock()
+
+	for _, hook := range hooks {
+		if hook.OnStart != nil {
+			if err := hook.OnStart(ctx); err != nil {
+				m.logger.Error("Error starting", zap.String("name", hook.Name), zap.Error(err))
+				return errors.Join(fmt.Errorf("%s: %w", hook.Name, err), m.Stop())
+			}
+		}
+
+		m.mutex.Lock()
+		m.started++
+		m.mutex.Unlock()
+	}
+
+	m.ready.Store(true)
+	return nil
+}
+
+func (m *manager) Wait(trap <-chan os.Signal) error {
+	select {
+	case signal := <-trap:
+		m.logger.Info("exiting by receiving a unix signal", zap.String("signal trap", signal.String()))
+		return nil
+	case err := <-m.failed:
+		return err
+	}
+}
+
+func (m *manager) Stop() error {
+	m.ready.Store(false)
+
+	m.mutex.Lock()
+	hooks := m.hooks[:m.started]
+	m.started = 0
+	m.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hooks[i].OnStop == nil {
+			continue
+		}
+
+		if err := hooks[i].OnStop(ctx); err != nil {
+			m.logger.Error("Error stopping", zap.String("name", hooks[i].Name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", hooks[i].Name, err))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background functions are still running: %w", ctx.Err()))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *manager) Ready() bool {
+	return m.ready.Load()
+}
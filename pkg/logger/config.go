@@ -1,7 +1,62 @@
 package logger
 
+import "time"
+
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+	OutputSyslog = "syslog"
+)
+
 type Config struct {
+	// Development enables zap.Development, e.g. DPanic panics, and colored
+	// levels and stacktraces from warn on the console outputs.
 	Development bool   `koanf:"development"`
 	Encoding    string `koanf:"encoding"`
-	Level       string `koanf:"level"`
+	Level       string `koanf:"level" reload:"true"`
+
+	// Outputs are any of stdout, stderr, file and syslog, every entry is
+	// written to all of them.
+	Outputs []string `koanf:"outputs"`
+
+	File     *FileConfig     `koanf:"file"`
+	Syslog   *SyslogConfig   `koanf:"syslog"`
+	Sampling *SamplingConfig `koanf:"sampling"`
+}
+
+// FileConfig of the file output, the file is rotated once it grows over MaxSize
+type FileConfig struct {
+	Path string `koanf:"path"`
+
+	// MaxSize in megabytes of the file before it's rotated
+	MaxSize int `koanf:"max_size"`
+
+	// MaxAge in days and MaxBackups are the limits of the rotated files which
+	// are kept, zero keeps them all.
+	MaxAge     int  `koanf:"max_age"`
+	MaxBackups int  `koanf:"max_backups"`
+	Compress   bool `koanf:"compress"`
+}
+
+// SyslogConfig of the syslog output, the local syslog socket is used when
+// Network and Address are empty.
+type SyslogConfig struct {
+	Network string `koanf:"network"`
+	Address string `koanf:"address"`
+	Tag     string `koanf:"tag"`
+}
+
+// SamplingConfig caps the entries with the same level and message which are
+// logged every Tick, the levels missing from Levels are never sampled.
+type SamplingConfig struct {
+	Tick   time.Duration                   `koanf:"tick"`
+	Levels map[string]*SamplingLevelConfig `koanf:"levels"`
+}
+
+// SamplingLevelConfig logs the first Initial entries of every Tick, then
+// every Thereafter-th one.
+type SamplingLevelConfig struct {
+	Initial    int `koanf:"initial"`
+	Thereafter int `koanf:"thereafter"`
 }
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIdKey
+)
+
+// NewContext returns a copy of ctx which carries the logger tagged with the fields
+func NewContext(ctx context.Context, lg *zap.Logger, fields ...zap.Field) context.Context {
+	return context.WithValue(ctx, loggerKey, lg.With(fields...))
+}
+
+// With adds the fields to the logger of ctx, e.g. the user id once the request is authenticated
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	return NewContext(ctx, FromContext(ctx), fields...)
+}
+
+// FromContext returns the logger of ctx, or the global logger when ctx carries
+// none, e.g. the calls which don't come from an http request.
+func FromContext(ctx context.Context) *zap.Logger {
+	if lg, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return lg
+	}
+
+	return zap.L()
+}
+
+// WithRequestId returns a copy of ctx which carries the request id, it's
+// forwarded to the services called on behalf of the request.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey, id)
+}
+
+// maxRequestIdLength bounds the ids accepted from the clients, longer ones are replaced
+const maxRequestIdLength = 128
+
+// ValidRequestId allows the printable ascii characters except space, so the id is safe to log and forward
+func ValidRequestId(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIdLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequestId returns the request id of ctx, or an empty string when it has none
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey).(string)
+	return id
+}
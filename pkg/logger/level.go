@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// level is shared by the loggers of NewZap, so their verbosity can be changed
+// at runtime without a restart.
+var level = zap.NewAtomicLevel()
+
+// LevelHandler serves the level of the loggers: GET returns it as
+// {"level":"info"} and PUT changes it, taking the same body.
+func LevelHandler() http.Handler {
+	return level
+}
+
+// SetLevel changes the level of the loggers, e.g. on a reload of the config
+func SetLevel(name string) error {
+	parsed, err := zapcore.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	level.SetLevel(parsed)
+	return nil
+}
@@ -1,26 +1,89 @@
 package logger
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func NewZap(cfg *Config) *zap.Logger {
-	return zap.New(
-		zapcore.NewCore(getEncoder(cfg), getWriteSyncer(cfg), getLoggerLevel(cfg)),
-		getOptions(cfg)...,
-	)
+// NewZap creates the logger and installs it as the global logger too, which
+// FromContext returns for the contexts without a logger of their own. The
+// fields of every entry are redacted, see Redact.
+func NewZap(cfg *Config) (*zap.Logger, error) {
+	level.SetLevel(getLoggerLevel(cfg))
+
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{OutputStdout}
+	}
+
+	cores := make([]zapcore.Core, 0, len(outputs))
+	for _, output := range outputs {
+		core, err := getOutputCore(cfg, output)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating %s log output\n%v", output, err)
+		}
+		cores = append(cores, core)
+	}
+
+	core, err := newSamplingCore(newRedactCore(zapcore.NewTee(cores...)), cfg.Sampling)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating log sampling\n%v", err)
+	}
+
+	lg := zap.New(core, getOptions(cfg)...)
+	zap.ReplaceGlobals(lg)
+
+	return lg, nil
+}
+
+func getOutputCore(cfg *Config, output string) (zapcore.Core, error) {
+	switch output {
+	case OutputStdout:
+		return zapcore.NewCore(getEncoder(cfg, true), zapcore.Lock(os.Stdout), level), nil
+	case OutputStderr:
+		return zapcore.NewCore(getEncoder(cfg, true), zapcore.Lock(os.Stderr), level), nil
+	case OutputFile:
+		if cfg.File == nil || len(cfg.File.Path) == 0 {
+			return nil, errors.New("the path of the log file is empty")
+		}
+
+		writer := &lumberjack.Logger{
+			Filename:   cfg.File.Path,
+			MaxSize:    cfg.File.MaxSize,
+			MaxAge:     cfg.File.MaxAge,
+			MaxBackups: cfg.File.MaxBackups,
+			Compress:   cfg.File.Compress,
+		}
+		return zapcore.NewCore(getEncoder(cfg, false), zapcore.AddSync(writer), level), nil
+	case OutputSyslog:
+		syslogCfg := cfg.Syslog
+		if syslogCfg == nil {
+			syslogCfg = &SyslogConfig{}
+		}
+		return newSyslogCore(syslogCfg, getEncoder(cfg, false), level)
+	default:
+		return nil, fmt.Errorf("unknown log output %q", output)
+	}
 }
 
-func getEncoder(cfg *Config) zapcore.Encoder {
+// getEncoder returns the encoder of an output, the levels are colored only on
+// the console outputs in development.
+func getEncoder(cfg *Config, console bool) zapcore.Encoder {
 	var encoderConfig zapcore.EncoderConfig
 	if cfg.Development {
 		encoderConfig = zap.NewDevelopmentEncoderConfig()
-		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	} else {
 		encoderConfig = zap.NewProductionEncoderConfig()
+	}
+
+	if cfg.Development && console {
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	} else {
 		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	}
 
@@ -36,21 +99,25 @@ func getEncoder(cfg *Config) zapcore.Encoder {
 	return encoder
 }
 
-func getWriteSyncer(cfg *Config) zapcore.WriteSyncer {
-	return zapcore.Lock(os.Stdout)
-}
-
-func getLoggerLevel(cfg *Config) zap.AtomicLevel {
+func getLoggerLevel(cfg *Config) zapcore.Level {
 	var level zapcore.Level
 
 	if err := level.Set(cfg.Level); err != nil {
-		return zap.NewAtomicLevelAt(zapcore.DebugLevel)
+		return zapcore.DebugLevel
 	}
 
-	return zap.NewAtomicLevelAt(level)
+	return level
 }
 
 func getOptions(cfg *Config) []zap.Option {
+	if cfg.Development {
+		return []zap.Option{
+			zap.Development(),
+			zap.AddStacktrace(zap.WarnLevel),
+			zap.AddCaller(),
+		}
+	}
+
 	return []zap.Option{
 		zap.AddStacktrace(zap.ErrorLevel),
 		zap.AddCaller(),
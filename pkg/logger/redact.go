@@ -0,0 +1,234 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Mask replaces the redacted values
+const Mask = "[REDACTED]"
+
+// secretSuffixes are the keys whose values are masked, they're matched against
+// the end of the lowercased key without separators, e.g. OldPassword or admin_token.
+var secretSuffixes = []string{"password", "passwd", "token", "secret", "key", "credentials", "authorization"}
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// HashEmail returns a stable digest of the email, so the log lines of an
+// address can be correlated without logging the address itself.
+func HashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// Email is the field of an email address, it's logged hashed
+func Email(key, email string) zap.Field {
+	return zap.String(key, HashEmail(email))
+}
+
+func isSecretKey(key string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "", ".", "").Replace(key))
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact returns a copy of the value which is safe to log or print, structs
+// and maps are turned into maps whose values are redacted recursively:
+//   - the fields tagged `redact:"true"` and the secret keys are masked
+//   - the fields tagged `redact:"hash"` and the email addresses are hashed
+//   - the fields tagged `redact:"false"` are kept as they are
+//
+// The keys of the structs are their json tags, or koanf tags, or field names.
+func Redact(value any) any {
+	if value == nil {
+		return nil
+	}
+
+	return redactValue(reflect.ValueOf(value))
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func redactValue(value reflect.Value) any {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch {
+	case value.Type() == timeType:
+		return value.Interface()
+	case value.Type() == durationType:
+		return value.Interface().(time.Duration).String()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return redactStruct(value)
+	case reflect.Map:
+		result := make(map[string]any, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			key, ok := iterator.Key().Interface().(string)
+			if !ok {
+				return Mask
+			}
+			result[key] = redactEntry(key, "", iterator.Value())
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return Mask
+		}
+
+		result := make([]any, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			result = append(result, redactValue(value.Index(i)))
+		}
+		return result
+	case reflect.String:
+		if emailPattern.MatchString(value.String()) {
+			return HashEmail(value.String())
+		}
+		return value.String()
+	default:
+		return value.Interface()
+	}
+}
+
+func redactStruct(value reflect.Value) map[string]any {
+	result := make(map[string]any, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		result[name] = redactEntry(name, field.Tag.Get("redact"), value.Field(i))
+	}
+
+	return result
+}
+
+func redactEntry(key, tag string, value reflect.Value) any {
+	switch {
+	case tag == "false":
+		return value.Interface()
+	case tag == "hash":
+		if value.Kind() == reflect.String {
+			return HashEmail(value.String())
+		}
+		return Mask
+	case tag == "true" || isSecretKey(key):
+		if value.IsZero() {
+			return value.Interface()
+		}
+		return Mask
+	default:
+		return redactValue(value)
+	}
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "koanf"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); len(name) != 0 {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+// MarshalRedacted adds the redacted fields of the struct to the encoder, it
+// implements zapcore.ObjectMarshaler for the structs which hold secrets.
+func MarshalRedacted(encoder zapcore.ObjectEncoder, value any) error {
+	fields, ok := Redact(value).(map[string]any)
+	if !ok {
+		return encoder.AddReflected("value", Redact(value))
+	}
+
+	for key, field := range fields {
+		if err := encoder.AddReflected(key, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// redactCore redacts the fields of every entry before they're encoded by the
+// wrapped core, the objects which marshal themselves are left to do their own.
+type redactCore struct {
+	zapcore.Core
+}
+
+func newRedactCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{core}
+}
+
+func (core *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{core.Core.With(redactFields(fields))}
+}
+
+func (core *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core.Enabled(entry.Level) {
+		return checked.AddCore(entry, core)
+	}
+
+	return checked
+}
+
+func (core *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return core.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		redacted = append(redacted, redactField(field))
+	}
+
+	return redacted
+}
+
+func redactField(field zapcore.Field) zapcore.Field {
+	if field.Type != zapcore.ErrorType && field.Type != zapcore.SkipType && isSecretKey(field.Key) {
+		return zap.String(field.Key, Mask)
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		if emailPattern.MatchString(field.String) {
+			return Email(field.Key, field.String)
+		}
+	case zapcore.ReflectType:
+		if marshaler, ok := field.Interface.(zapcore.ObjectMarshaler); ok {
+			return zap.Object(field.Key, marshaler)
+		}
+		return zap.Reflect(field.Key, Redact(field.Interface))
+	}
+
+	return field
+}
@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type credentials struct {
+	Email       string `json:"email"`
+	OldPassword string
+	APIKey      string `koanf:"api_key"`
+	Secret      string `redact:"true"`
+	Owner       string `redact:"hash"`
+	Public      string `redact:"false" json:"public_token"`
+	Empty       string `json:"token"`
+	Ignored     string `json:"-"`
+	Timeout     time.Duration
+	Nested      *credentials
+	hidden      string
+}
+
+// safe marshals itself, so the core must leave it alone
+type safe struct{ Password string }
+
+func (s safe) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddString("password", "marshaled")
+	return nil
+}
+
+func TestIsSecretKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{key: "password", expected: true},
+		{key: "OldPassword", expected: true},
+		{key: "admin_token", expected: true},
+		{key: "X-API-Key", expected: true},
+		{key: "client_secret", expected: true},
+		{key: "Authorization", expected: true},
+		{key: "grpc.credentials", expected: true},
+		{key: "email"},
+		{key: "keyboard"},
+		{key: "tokens_total"},
+	}
+
+	for _, test := range tests {
+		if secret := isSecretKey(test.key); secret != test.expected {
+			t.Errorf("expected %s to be secret %v, got %v", test.key, test.expected, secret)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	value := &credentials{
+		Email:       "User@Example.com",
+		OldPassword: "old",
+		APIKey:      "key",
+		Secret:      "secret",
+		Owner:       "owner",
+		Public:      "public",
+		Ignored:     "ignored",
+		Timeout:     time.Second,
+		Nested:      &credentials{Secret: "nested"},
+		hidden:      "hidden",
+	}
+
+	redacted, ok := Redact(value).(map[string]any)
+	if !ok {
+		t.Fatalf("expected a map, got %T", Redact(value))
+	}
+
+	expected := map[string]any{
+		"email":        HashEmail("user@example.com"),
+		"OldPassword":  Mask,
+		"api_key":      Mask,
+		"Secret":       Mask,
+		"Owner":        HashEmail("owner"),
+		"public_token": "public",
+		"token":        "",
+		"Timeout":      "1s",
+	}
+	for key, value := range expected {
+		if redacted[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, redacted[key])
+		}
+	}
+
+	for _, key := range []string{"Ignored", "-", "hidden"} {
+		if _, ok := redacted[key]; ok {
+			t.Errorf("expected %s to be skipped", key)
+		}
+	}
+
+	if nested := redacted["Nested"].(map[string]any); nested["Secret"] != Mask {
+		t.Errorf("expected the nested secret to be masked, got %v", nested["Secret"])
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "nil", value: nil, expected: "<nil>"},
+		{name: "email", value: "a@b.co", expected: HashEmail("a@b.co")},
+		{name: "plain string", value: "hello", expected: "hello"},
+		{name: "bytes", value: []byte("secret"), expected: Mask},
+		{name: "map", value: map[string]string{"password": "p", "name": "n"}, expected: "map[name:n password:[REDACTED]]"},
+		{name: "map of non string keys", value: map[int]string{1: "a"}, expected: Mask},
+		{name: "slice of emails", value: []string{"a@b.co", "x"}, expected: fmt.Sprint([]any{HashEmail("a@b.co"), "x"})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if redacted := fmt.Sprint(Redact(test.value)); redacted != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, redacted)
+			}
+		})
+	}
+}
+
+func TestMarshalRedacted(t *testing.T) {
+	encoder := zapcore.NewMapObjectEncoder()
+	if err := MarshalRedacted(encoder, &credentials{Email: "a@b.co", Secret: "secret"}); err != nil {
+		t.Fatalf("marshaling: %v", err)
+	}
+
+	if encoder.Fields["Secret"] != Mask || encoder.Fields["email"] != HashEmail("a@b.co") {
+		t.Errorf("expected the redacted fields, got %v", encoder.Fields)
+	}
+}
+
+func TestRedactCore(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	lg := zap.New(newRedactCore(core)).With(zap.String("token", "with"))
+
+	lg.Info("entry",
+		zap.String("password", "plain"),
+		zap.String("email", "a@b.co"),
+		zap.String("name", "name"),
+		zap.Error(fmt.Errorf("error")),
+		zap.Any("request", credentials{OldPassword: "old"}),
+		zap.Any("marshaler", safe{Password: "plain"}),
+	)
+
+	fields := logs.All()[0].ContextMap()
+	expected := map[string]any{
+		"token":    Mask,
+		"password": Mask,
+		"email":    HashEmail("a@b.co"),
+		"name":     "name",
+		"error":    "error",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, fields[key])
+		}
+	}
+
+	if request := fields["request"].(map[string]any); request["OldPassword"] != Mask {
+		t.Errorf("expected the reflected secret to be masked, got %v", request["OldPassword"])
+	}
+
+	if marshaler := fields["marshaler"].(map[string]any); marshaler["password"] != "marshaled" {
+		t.Errorf("expected the object marshaler to be used, got %v", marshaler)
+	}
+}
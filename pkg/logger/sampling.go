@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// samplingCore samples the entries of every level with a sampler of its own,
+// the entries of the other levels go to the wrapped core as they are.
+type samplingCore struct {
+	zapcore.Core
+	samplers map[zapcore.Level]zapcore.Core
+}
+
+func newSamplingCore(core zapcore.Core, cfg *SamplingConfig) (zapcore.Core, error) {
+	if cfg == nil || len(cfg.Levels) == 0 {
+		return core, nil
+	}
+
+	samplers := make(map[zapcore.Level]zapcore.Core, len(cfg.Levels))
+	for name, levelCfg := range cfg.Levels {
+		level, err := zapcore.ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sampling level %q", name)
+		}
+
+		if levelCfg == nil || levelCfg.Initial <= 0 {
+			continue
+		}
+
+		samplers[level] = zapcore.NewSamplerWithOptions(core, cfg.Tick, levelCfg.Initial, levelCfg.Thereafter)
+	}
+
+	return &samplingCore{Core: core, samplers: samplers}, nil
+}
+
+func (core *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	samplers := make(map[zapcore.Level]zapcore.Core, len(core.samplers))
+	for level, sampler := range core.samplers {
+		samplers[level] = sampler.With(fields)
+	}
+
+	return &samplingCore{Core: core.Core.With(fields), samplers: samplers}
+}
+
+func (core *samplingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if sampler, ok := core.samplers[entry.Level]; ok {
+		return sampler.Check(entry, checked)
+	}
+
+	return core.Core.Check(entry, checked)
+}
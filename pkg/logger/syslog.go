@@ -0,0 +1,68 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"log/syslog"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// syslogCore writes every entry with the syslog severity of its level
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *syslog.Writer
+}
+
+func newSyslogCore(cfg *SyslogConfig, encoder zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, error) {
+	writer, err := syslog.Dial(cfg.Network, cfg.Address, syslog.LOG_INFO|syslog.LOG_USER, cfg.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogCore{LevelEnabler: enabler, encoder: encoder, writer: writer}, nil
+}
+
+func (core *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := core.encoder.Clone()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	return &syslogCore{LevelEnabler: core.LevelEnabler, encoder: encoder, writer: core.writer}
+}
+
+func (core *syslogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core.Enabled(entry.Level) {
+		return checked.AddCore(entry, core)
+	}
+
+	return checked
+}
+
+func (core *syslogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buffer, err := core.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	defer buffer.Free()
+
+	message := buffer.String()
+	switch entry.Level {
+	case zapcore.DebugLevel:
+		return core.writer.Debug(message)
+	case zapcore.InfoLevel:
+		return core.writer.Info(message)
+	case zapcore.WarnLevel:
+		return core.writer.Warning(message)
+	case zapcore.ErrorLevel:
+		return core.writer.Err(message)
+	default:
+		return core.writer.Crit(message)
+	}
+}
+
+func (core *syslogCore) Sync() error {
+	return nil
+}
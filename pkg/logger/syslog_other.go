@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package logger
+
+import (
+	"errors"
+	"runtime"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func newSyslogCore(cfg *SyslogConfig, encoder zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, error) {
+	return nil, errors.New("syslog is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,8 @@
+package mailer
+
+type Config struct {
+	// Driver is either log or file
+	Driver    string `koanf:"driver"`
+	From      string `koanf:"from" redact:"false"`
+	Directory string `koanf:"directory"`
+}
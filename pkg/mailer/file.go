@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileMailer stores every message as an .eml file in the directory
+type fileMailer struct {
+	from      string
+	directory string
+}
+
+func NewFile(cfg *Config) (Mailer, error) {
+	if err := os.MkdirAll(cfg.Directory, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating mail directory: %w", err)
+	}
+
+	return &fileMailer{from: cfg.From, directory: cfg.Directory}, nil
+}
+
+func (m *fileMailer) Send(_ context.Context, message *Message) error {
+	now := time.Now()
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(message.To)
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), recipient)
+
+	content := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		m.from, message.To, message.Subject, now.Format(time.RFC1123Z), message.Body,
+	)
+
+	if err := os.WriteFile(filepath.Join(m.directory, name), []byte(content), 0o640); err != nil {
+		return fmt.Errorf("error writing mail file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// logMailer writes the messages to the logger instead of delivering them,
+// it is meant for development where no smtp server is available.
+type logMailer struct {
+	from   string
+	logger *zap.Logger
+}
+
+func NewLog(cfg *Config, lg *zap.Logger) Mailer {
+	return &logMailer{from: cfg.From, logger: lg}
+}
+
+func (m *logMailer) Send(_ context.Context, message *Message) error {
+	m.logger.Info("Sending email",
+		zap.String("from", m.from),
+		zap.String("to", message.To),
+		zap.String("subject", message.Subject),
+		zap.String("body", message.Body),
+	)
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+func New(cfg *Config, lg *zap.Logger) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverLog:
+		return NewLog(cfg, lg), nil
+	case DriverFile:
+		return NewFile(cfg)
+	default:
+		return nil, fmt.Errorf("unknown mailer driver: %s", cfg.Driver)
+	}
+}
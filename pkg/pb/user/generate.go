@@ -0,0 +1,5 @@
+// Package user contains the protocol buffers of the user service, the
+// generated code is committed so the other services can import it directly.
+package user
+
+//go:generate protoc --proto_path=. --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative user.proto
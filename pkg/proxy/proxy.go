@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Trusted are the networks of the proxies whose forwarded addresses are trusted
+type Trusted []*net.IPNet
+
+// Parse parses the trusted proxies, addresses or CIDR ranges, the invalid ones
+// are skipped.
+func Parse(lg *zap.Logger, proxies []string) Trusted {
+	networks := make(Trusted, 0, len(proxies))
+	for _, proxy := range proxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			lg.Error("Error invalid trusted proxy", zap.String("proxy", proxy))
+			continue
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	return networks
+}
+
+func (trusted Trusted) Contains(ip net.IP) bool {
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns the client of the comma separated forwarded addresses, sent
+// by a trusted proxy. They are read from the right and the first address which
+// is not a trusted proxy is the client, since the addresses on its left are
+// sent by the client and can be forged. It's nil when there is no such address.
+func (trusted Trusted) ClientIP(forwarded string) net.IP {
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return nil
+		}
+
+		if !trusted.Contains(ip) {
+			return ip
+		}
+	}
+
+	return nil
+}
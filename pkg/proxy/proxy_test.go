@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestParse(t *testing.T) {
+	trusted := Parse(zap.NewNop(), []string{"10.0.0.0/8", "192.168.1.1", "::1", "invalid"})
+	if len(trusted) != 3 {
+		t.Fatalf("expected the invalid proxy to be skipped, got %v", trusted)
+	}
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{ip: "10.1.2.3", expected: true},
+		{ip: "192.168.1.1", expected: true},
+		{ip: "192.168.1.2"},
+		{ip: "::1", expected: true},
+		{ip: "::2"},
+	}
+
+	for _, test := range tests {
+		if contains := trusted.Contains(net.ParseIP(test.ip)); contains != test.expected {
+			t.Errorf("expected %s to be trusted %v, got %v", test.ip, test.expected, contains)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := Parse(zap.NewNop(), []string{"10.0.0.0/8"})
+
+	tests := []struct {
+		name      string
+		forwarded string
+		expected  string
+	}{
+		{name: "single", forwarded: "1.1.1.1", expected: "1.1.1.1"},
+		{name: "last untrusted", forwarded: "2.2.2.2, 1.1.1.1", expected: "1.1.1.1"},
+		{name: "trusted hops skipped", forwarded: "2.2.2.2, 1.1.1.1, 10.0.0.2, 10.0.0.1", expected: "1.1.1.1"},
+		{name: "spaces", forwarded: " 1.1.1.1 ,10.0.0.1 ", expected: "1.1.1.1"},
+		{name: "only trusted", forwarded: "10.0.0.2, 10.0.0.1"},
+		{name: "empty", forwarded: ""},
+		{name: "invalid hop", forwarded: "1.1.1.1, forged, 10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ip := trusted.ClientIP(test.forwarded)
+			if got := ip.String(); (ip == nil && len(test.expected) != 0) || (ip != nil && got != test.expected) {
+				t.Errorf("expected %q, got %v", test.expected, ip)
+			}
+		})
+	}
+}
@@ -1,9 +1,32 @@
 package rdbms
 
+// Drivers of the Config
+const (
+	DriverPostgres = "postgres"
+	DriverMysql    = "mysql"
+	DriverSqlite   = "sqlite"
+)
+
 type Config struct {
+	Driver   string `koanf:"driver"`
 	Host     string `koanf:"host"`
 	Port     int    `koanf:"port"`
 	Username string `koanf:"username"`
-	Password string `koanf:"password"`
+	Password string `koanf:"password" redact:"true"`
 	Database string `koanf:"database"`
+
+	// Path is the database file of sqlite, or Memory for an in-memory database
+	Path string `koanf:"path"`
+
+	// Isolation is the isolation level of the transactions, one of
+	// read_committed, repeatable_read or serializable, or empty for the
+	// default of the database
+	Isolation string `koanf:"isolation"`
+	// TxRetries is how many times a transaction is retried on serialization failures
+	TxRetries int `koanf:"tx_retries"`
+}
+
+// InMemory reports whether the database starts empty on every run
+func (cfg *Config) InMemory() bool {
+	return cfg.Driver == DriverSqlite && cfg.Path == Memory
 }
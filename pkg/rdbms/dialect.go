@@ -0,0 +1,210 @@
+package rdbms
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect hides the syntax differences of the databases, the queries are
+// written with the $1..$n placeholders of Postgres and rewritten by Rebind.
+type Dialect interface {
+	// Name of the dialect, it's also the name of its migration directory
+	Name() string
+
+	// Rebind rewrites the $n placeholders of the query and orders the args
+	// accordingly, placeholders inside string literals are left untouched.
+	// The placeholder of a slice arg is expanded to one per element, so
+	// "id IN ($1)" works with a slice of ids. Clauses the dialect has no use
+	// for, e.g. FOR UPDATE, are removed as well.
+	Rebind(query string, args []any) (string, []any)
+
+	// Returning reports whether INSERT ... RETURNING id is supported, otherwise
+	// Create removes the clause and uses the last insert id instead.
+	Returning() bool
+
+	// Upsert returns an insert of the columns which updates the non-key columns
+	// when a row with the same keys exists, the values are $1..$n in the order of columns.
+	Upsert(table string, keys, columns []string) string
+
+	// InsertIgnore returns an insert of the columns which does nothing when a
+	// row with the same keys exists, the values are $1..$n in the order of columns.
+	InsertIgnore(table string, columns []string) string
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return DriverPostgres }
+
+func (postgresDialect) Rebind(query string, args []any) (string, []any) {
+	return rebind(query, args, func(position int) string { return "$" + strconv.Itoa(position) })
+}
+
+func (postgresDialect) Returning() bool { return true }
+
+func (postgresDialect) Upsert(table string, keys, columns []string) string {
+	updates := make([]string, 0, len(columns))
+	for _, column := range nonKeyColumns(keys, columns) {
+		updates = append(updates, column+"=EXCLUDED."+column)
+	}
+
+	return insert(table, columns) +
+		" ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(updates, ", ") + ";"
+}
+
+func (postgresDialect) InsertIgnore(table string, columns []string) string {
+	return insert(table, columns) + " ON CONFLICT DO NOTHING;"
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return DriverMysql }
+
+func (mysqlDialect) Rebind(query string, args []any) (string, []any) {
+	return rebind(query, args, func(int) string { return "?" })
+}
+
+func (mysqlDialect) Returning() bool { return false }
+
+func (mysqlDialect) Upsert(table string, keys, columns []string) string {
+	updates := make([]string, 0, len(columns))
+	for _, column := range nonKeyColumns(keys, columns) {
+		updates = append(updates, column+"=VALUES("+column+")")
+	}
+
+	return insert(table, columns) + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ") + ";"
+}
+
+func (mysqlDialect) InsertIgnore(table string, columns []string) string {
+	return "INSERT IGNORE" + strings.TrimPrefix(insert(table, columns), "INSERT") + ";"
+}
+
+type sqliteDialect struct{}
+
+// forUpdateClause is removed since sqlite locks the whole database for the writes
+var forUpdateClause = regexp.MustCompile(`(?i)\s+FOR\s+UPDATE\b`)
+
+func (sqliteDialect) Name() string { return DriverSqlite }
+
+func (sqliteDialect) Rebind(query string, args []any) (string, []any) {
+	query = forUpdateClause.ReplaceAllString(query, "")
+	return rebind(query, args, func(position int) string { return "?" + strconv.Itoa(position) })
+}
+
+func (sqliteDialect) Returning() bool { return true }
+
+func (sqliteDialect) Upsert(table string, keys, columns []string) string {
+	return postgresDialect{}.Upsert(table, keys, columns)
+}
+
+func (sqliteDialect) InsertIgnore(table string, columns []string) string {
+	return postgresDialect{}.InsertIgnore(table, columns)
+}
+
+// rebind replaces every $n placeholder using the placeholder function with
+// the position of the replacement, the args are repeated and reordered to
+// match the positions since $n may appear more than once or out of order.
+// Slice args are expanded to a placeholder per element, or NULL when empty.
+func rebind(query string, args []any, placeholder func(position int) string) (string, []any) {
+	var builder strings.Builder
+	builder.Grow(len(query))
+	rebound := make([]any, 0, len(args))
+
+	quoted := false
+	for i := 0; i < len(query); i++ {
+		char := query[i]
+		if char == '\'' {
+			quoted = !quoted
+		}
+
+		if quoted || char != '$' {
+			builder.WriteByte(char)
+			continue
+		}
+
+		end := i + 1
+		for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+			end++
+		}
+
+		n, err := strconv.Atoi(query[i+1 : end])
+		if err != nil || n < 1 || n > len(args) {
+			builder.WriteByte(char)
+			continue
+		}
+
+		elements, isList := expand(args[n-1])
+		if !isList {
+			elements = args[n-1 : n]
+		} else if len(elements) == 0 {
+			builder.WriteString("NULL")
+		}
+
+		for j, element := range elements {
+			if j != 0 {
+				builder.WriteString(", ")
+			}
+
+			rebound = append(rebound, element)
+			builder.WriteString(placeholder(len(rebound)))
+		}
+
+		i = end - 1
+	}
+
+	return builder.String(), rebound
+}
+
+// expand returns the elements of the slice args, except []byte and the
+// driver.Valuer slices which are sent as a single value.
+func expand(arg any) ([]any, bool) {
+	if _, ok := arg.(driver.Valuer); ok {
+		return nil, false
+	}
+
+	value := reflect.ValueOf(arg)
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+
+	elements := make([]any, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		elements = append(elements, value.Index(i).Interface())
+	}
+
+	return elements, true
+}
+
+var returningClause = regexp.MustCompile(`(?is)\s+RETURNING\s+[\w\s,]+;?\s*$`)
+
+// withoutReturning removes the trailing RETURNING clause of the query
+func withoutReturning(query string) string {
+	return returningClause.ReplaceAllString(query, ";")
+}
+
+func insert(table string, columns []string) string {
+	values := make([]string, 0, len(columns))
+	for i := range columns {
+		values = append(values, "$"+strconv.Itoa(i+1))
+	}
+
+	return "INSERT INTO " + table + "(" + strings.Join(columns, ", ") + ") VALUES(" + strings.Join(values, ", ") + ")"
+}
+
+func nonKeyColumns(keys, columns []string) []string {
+	result := make([]string, 0, len(columns))
+	for _, column := range columns {
+		isKey := false
+		for _, key := range keys {
+			isKey = isKey || key == column
+		}
+
+		if !isKey {
+			result = append(result, column)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,172 @@
+package rdbms
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		query    string
+		args     []any
+		expected string
+		rebound  []any
+	}{
+		{
+			name:     "postgres",
+			dialect:  postgresDialect{},
+			query:    "SELECT * FROM users WHERE id=$1 AND email=$2;",
+			args:     []any{1, "a@b.co"},
+			expected: "SELECT * FROM users WHERE id=$1 AND email=$2;",
+			rebound:  []any{1, "a@b.co"},
+		},
+		{
+			name:     "mysql reordered",
+			dialect:  mysqlDialect{},
+			query:    "UPDATE users SET email=$2 WHERE id=$1;",
+			args:     []any{1, "a@b.co"},
+			expected: "UPDATE users SET email=? WHERE id=?;",
+			rebound:  []any{"a@b.co", 1},
+		},
+		{
+			name:     "mysql repeated",
+			dialect:  mysqlDialect{},
+			query:    "SELECT * FROM tokens WHERE user_id=$1 OR owner_id=$1;",
+			args:     []any{7},
+			expected: "SELECT * FROM tokens WHERE user_id=? OR owner_id=?;",
+			rebound:  []any{7, 7},
+		},
+		{
+			name:     "sqlite",
+			dialect:  sqliteDialect{},
+			query:    "UPDATE users SET email=$2 WHERE id=$1;",
+			args:     []any{1, "a@b.co"},
+			expected: "UPDATE users SET email=?1 WHERE id=?2;",
+			rebound:  []any{"a@b.co", 1},
+		},
+		{
+			name:     "sqlite removes for update",
+			dialect:  sqliteDialect{},
+			query:    "SELECT id FROM tokens WHERE hash=$1 FOR UPDATE;",
+			args:     []any{"hash"},
+			expected: "SELECT id FROM tokens WHERE hash=?1;",
+			rebound:  []any{"hash"},
+		},
+		{
+			name:     "string literals",
+			dialect:  mysqlDialect{},
+			query:    "SELECT '$1' FROM users WHERE id=$1;",
+			args:     []any{1},
+			expected: "SELECT '$1' FROM users WHERE id=?;",
+			rebound:  []any{1},
+		},
+		{
+			name:     "postgres list",
+			dialect:  postgresDialect{},
+			query:    "SELECT * FROM users WHERE id IN ($1) AND email=$2;",
+			args:     []any{[]uint64{1, 2, 3}, "a@b.co"},
+			expected: "SELECT * FROM users WHERE id IN ($1, $2, $3) AND email=$4;",
+			rebound:  []any{uint64(1), uint64(2), uint64(3), "a@b.co"},
+		},
+		{
+			name:     "mysql list",
+			dialect:  mysqlDialect{},
+			query:    "SELECT * FROM users WHERE id IN ($1);",
+			args:     []any{[]uint64{1, 2}},
+			expected: "SELECT * FROM users WHERE id IN (?, ?);",
+			rebound:  []any{uint64(1), uint64(2)},
+		},
+		{
+			name:     "empty list",
+			dialect:  postgresDialect{},
+			query:    "SELECT * FROM users WHERE id IN ($1);",
+			args:     []any{[]uint64{}},
+			expected: "SELECT * FROM users WHERE id IN (NULL);",
+			rebound:  []any{},
+		},
+		{
+			name:     "bytes are not a list",
+			dialect:  postgresDialect{},
+			query:    "UPDATE users SET secret=$1;",
+			args:     []any{[]byte("secret")},
+			expected: "UPDATE users SET secret=$1;",
+			rebound:  []any{[]byte("secret")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, args := test.dialect.Rebind(test.query, test.args)
+			if query != test.expected {
+				t.Errorf("expected query %q, got %q", test.expected, query)
+			}
+
+			if fmt.Sprint(args) != fmt.Sprint(test.rebound) {
+				t.Errorf("expected args %v, got %v", test.rebound, args)
+			}
+		})
+	}
+}
+
+func TestWithoutReturning(t *testing.T) {
+	query := withoutReturning("INSERT INTO users(email) VALUES($1) RETURNING id;")
+	if expected := "INSERT INTO users(email) VALUES($1);"; query != expected {
+		t.Errorf("expected %q, got %q", expected, query)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	keys, columns := []string{"attempt_key"}, []string{"attempt_key", "failures", "blocked_until"}
+
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{
+			dialect: postgresDialect{},
+			expected: "INSERT INTO login_attempts(attempt_key, failures, blocked_until) VALUES($1, $2, $3)" +
+				" ON CONFLICT (attempt_key) DO UPDATE SET failures=EXCLUDED.failures, blocked_until=EXCLUDED.blocked_until;",
+		},
+		{
+			dialect: mysqlDialect{},
+			expected: "INSERT INTO login_attempts(attempt_key, failures, blocked_until) VALUES($1, $2, $3)" +
+				" ON DUPLICATE KEY UPDATE failures=VALUES(failures), blocked_until=VALUES(blocked_until);",
+		},
+		{
+			dialect: sqliteDialect{},
+			expected: "INSERT INTO login_attempts(attempt_key, failures, blocked_until) VALUES($1, $2, $3)" +
+				" ON CONFLICT (attempt_key) DO UPDATE SET failures=EXCLUDED.failures, blocked_until=EXCLUDED.blocked_until;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.dialect.Name(), func(t *testing.T) {
+			if query := test.dialect.Upsert("login_attempts", keys, columns); query != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, query)
+			}
+		})
+	}
+}
+
+func TestInsertIgnore(t *testing.T) {
+	columns := []string{"attempt_key", "failures"}
+
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{dialect: postgresDialect{}, expected: "INSERT INTO login_attempts(attempt_key, failures) VALUES($1, $2) ON CONFLICT DO NOTHING;"},
+		{dialect: mysqlDialect{}, expected: "INSERT IGNORE INTO login_attempts(attempt_key, failures) VALUES($1, $2);"},
+		{dialect: sqliteDialect{}, expected: "INSERT INTO login_attempts(attempt_key, failures) VALUES($1, $2) ON CONFLICT DO NOTHING;"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.dialect.Name(), func(t *testing.T) {
+			if query := test.dialect.InsertIgnore("login_attempts", columns); query != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, query)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package rdbms
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors of the operations, every error returned by RDBMS wraps one of them
+var (
+	ErrBegin  = errors.New("error when tying to begin transaction")
+	ErrCommit = errors.New("error when tying to commit transaction")
+
+	ErrCreate = errors.New("error when tying to create entry")
+
+	ErrRead         = errors.New("error when tying to read entry")
+	ErrReadNotFound = errors.New("there is no entry with provided arguments")
+
+	ErrUpdate = errors.New("error when tying to update entry")
+
+	ErrDelete = errors.New("error when tying to delete entry")
+)
+
+// Errors of the constraint violations and conflicts, mapped from the driver error codes
+var (
+	ErrDuplicate     = errors.New("entry exists")
+	ErrForeignKey    = errors.New("referenced entry does not exist or is still referenced")
+	ErrNotNull       = errors.New("required value is missing")
+	ErrSerialization = errors.New("transaction conflicted with a concurrent one")
+)
+
+// classifier maps the errors of a driver to the constraint errors above, it
+// returns nil for the errors it doesn't know.
+type classifier func(err error) error
+
+// wrap annotates the driver error with the operation and the constraint it
+// violated, so both can be checked with errors.Is.
+func (db *executor) wrap(operation error, err error) error {
+	if db.classify != nil {
+		if kind := db.classify(err); kind != nil {
+			return fmt.Errorf("%w: %w: %w", operation, kind, err)
+		}
+	}
+
+	return fmt.Errorf("%w: %w", operation, err)
+}
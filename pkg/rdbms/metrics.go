@@ -0,0 +1,76 @@
+package rdbms
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operations are the values of the operation label of the statement metrics
+const (
+	OperationCreate  = "create"
+	OperationRead    = "read"
+	OperationIterate = "iterate"
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+)
+
+var (
+	statementDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "user",
+		Subsystem: "rdbms",
+		Name:      "statement_duration_seconds",
+		Help:      "Duration of the statements by driver and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"driver", "operation"})
+
+	statementErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "user",
+		Subsystem: "rdbms",
+		Name:      "statement_errors_total",
+		Help:      "Failed statements by driver and operation, a read without rows is not a failure.",
+	}, []string{"driver", "operation"})
+)
+
+var tracer = otel.Tracer("github.com/CafeKetab/user/pkg/rdbms")
+
+// observe starts the client span of a statement, the returned function ends
+// it and records the duration and the failure of the statement. It's deferred
+// with the address of the named error result of the method.
+func (db *executor) observe(ctx context.Context, operation, query string) (context.Context, func(err *error)) {
+	driver := db.dialect.Name()
+
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "rdbms."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", driver),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", query),
+		),
+	)
+
+	return ctx, func(err *error) {
+		defer span.End()
+		statementDuration.WithLabelValues(driver, operation).Observe(time.Since(start).Seconds())
+
+		if *err != nil && !errors.Is(*err, ErrReadNotFound) {
+			statementErrors.WithLabelValues(driver, operation).Inc()
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+	}
+}
+
+// Collector exposes the connection pool stats of sql.DB as the go_sql_* metrics
+func (db *rdbms) Collector() prometheus.Collector {
+	return collectors.NewDBStatsCollector(db.db, db.dialect.Name())
+}
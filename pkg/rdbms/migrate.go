@@ -0,0 +1,37 @@
+package rdbms
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationsLock is the name of the advisory lock held while migrating, it
+// differs from the lock of golang-migrate which is only held per operation.
+const migrationsLock = "user_migrations"
+
+// lockId is the numeric id of the lock for the databases which don't accept names
+func lockId(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return int64(hash.Sum64())
+}
+
+func runMigrate(files fs.FS, dir, databaseName string, instance database.Driver, fn func(*migrate.Migrate) error) error {
+	source, err := iofs.New(files, dir)
+	if err != nil {
+		return fmt.Errorf("Error loading migration files\n%v", err)
+	}
+	defer source.Close()
+
+	migration, err := migrate.NewWithInstance("iofs", source, databaseName, instance)
+	if err != nil {
+		return fmt.Errorf("Error creating migrate instance\n%v", err)
+	}
+
+	return fn(migration)
+}
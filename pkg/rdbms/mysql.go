@@ -1,13 +1,15 @@
 package rdbms
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 
-	_ "github.com/go-sql-driver/mysql"
+	driver "github.com/go-sql-driver/mysql"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/mysql"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 type mysqlWrapper struct {
@@ -16,7 +18,7 @@ type mysqlWrapper struct {
 
 func NewMysql(cfg *Config) (RDBMS, error) {
 	connString := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=true",
+		"%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=true&multiStatements=true",
 		cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.Database,
 	)
 
@@ -26,36 +28,60 @@ func NewMysql(cfg *Config) (RDBMS, error) {
 	}
 
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("Error ping database:\n%v", err)
 	}
 
-	return &mysqlWrapper{&rdbms{db: db}}, nil
-}
+	rdbms, err := newRDBMS(db, cfg, mysqlDialect{}, classifyMysql)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
-func (db *mysqlWrapper) MigrateUp(source string) error {
-	migrator := func(m *migrate.Migrate) error { return m.Up() }
-	return db.migrate(source, migrator)
+	return &mysqlWrapper{rdbms}, nil
 }
 
-func (db *mysqlWrapper) MigrateDown(source string) error {
-	migrator := func(m *migrate.Migrate) error { return m.Down() }
-	return db.migrate(source, migrator)
+// classifyMysql maps the error numbers of https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+func classifyMysql(err error) error {
+	var mysqlErr *driver.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+
+	switch mysqlErr.Number {
+	case 1062: // ER_DUP_ENTRY
+		return ErrDuplicate
+	case 1451, 1452: // ER_ROW_IS_REFERENCED_2, ER_NO_REFERENCED_ROW_2
+		return ErrForeignKey
+	case 1048: // ER_BAD_NULL_ERROR
+		return ErrNotNull
+	case 1213, 1205: // ER_LOCK_DEADLOCK, ER_LOCK_WAIT_TIMEOUT
+		return ErrSerialization
+	default:
+		return nil
+	}
 }
 
-func (db *mysqlWrapper) migrate(source string, migrator func(*migrate.Migrate) error) error {
-	instance, err := mysql.WithInstance(db.db, &mysql.Config{})
+func (db *mysqlWrapper) Migrate(ctx context.Context, files fs.FS, dir string, fn func(*migrate.Migrate) error) error {
+	conn, err := db.db.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("Error creating migrate instance\n%v", err)
+		return fmt.Errorf("Error getting connection\n%v", err)
 	}
+	defer conn.Close()
 
-	migration, err := migrate.NewWithDatabaseInstance(source, "mysql", instance)
-	if err != nil {
-		return fmt.Errorf("Error loading migration files\n%v", err)
+	// a negative timeout waits until the lock is released
+	var locked int
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1);", migrationsLock).Scan(&locked); err != nil {
+		return fmt.Errorf("Error acquiring the migrations lock\n%v", err)
+	} else if locked != 1 {
+		return errors.New("Error acquiring the migrations lock")
 	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?);", migrationsLock)
 
-	if err := migrator(migration); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("Error doing migrations\n%v", err)
+	instance, err := mysql.WithConnection(ctx, conn, &mysql.Config{})
+	if err != nil {
+		return fmt.Errorf("Error creating migrate instance\n%v", err)
 	}
 
-	return nil
+	return runMigrate(files, dir, "mysql", instance, fn)
 }
@@ -1,13 +1,15 @@
 package rdbms
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type postgresWrapper struct {
@@ -26,36 +28,57 @@ func NewPostgres(cfg *Config) (RDBMS, error) {
 	}
 
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("Error ping database:\n%v", err)
 	}
 
-	return &postgresWrapper{&rdbms{db: db}}, nil
-}
+	rdbms, err := newRDBMS(db, cfg, postgresDialect{}, classifyPostgres)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
-func (db *postgresWrapper) MigrateUp(source string) error {
-	migrator := func(m *migrate.Migrate) error { return m.Up() }
-	return db.migrate(source, migrator)
+	return &postgresWrapper{rdbms}, nil
 }
 
-func (db *postgresWrapper) MigrateDown(source string) error {
-	migrator := func(m *migrate.Migrate) error { return m.Down() }
-	return db.migrate(source, migrator)
+// classifyPostgres maps the SQLSTATE codes of https://www.postgresql.org/docs/current/errcodes-appendix.html
+func classifyPostgres(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return ErrDuplicate
+	case "23503": // foreign_key_violation
+		return ErrForeignKey
+	case "23502": // not_null_violation
+		return ErrNotNull
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return ErrSerialization
+	default:
+		return nil
+	}
 }
 
-func (db *postgresWrapper) migrate(source string, migrator func(*migrate.Migrate) error) error {
-	instance, err := postgres.WithInstance(db.db, &postgres.Config{})
+func (db *postgresWrapper) Migrate(ctx context.Context, files fs.FS, dir string, fn func(*migrate.Migrate) error) error {
+	conn, err := db.db.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("Error creating migrate instance\n%v", err)
+		return fmt.Errorf("Error getting connection\n%v", err)
 	}
+	defer conn.Close()
 
-	migration, err := migrate.NewWithDatabaseInstance(source, "postgres", instance)
-	if err != nil {
-		return fmt.Errorf("Error loading migration files\n%v", err)
+	id := lockId(migrationsLock)
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1);", id); err != nil {
+		return fmt.Errorf("Error acquiring the migrations lock\n%v", err)
 	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1);", id)
 
-	if err := migrator(migration); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("Error doing migrations\n%v", err)
+	instance, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		return fmt.Errorf("Error creating migrate instance\n%v", err)
 	}
 
-	return nil
+	return runMigrate(files, dir, "postgres", instance, fn)
 }
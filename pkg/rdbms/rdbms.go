@@ -1,106 +1,224 @@
 package rdbms
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Executor runs the statements, it's implemented by the database itself and
+// by the transactions started with WithTx.
+type Executor interface {
+	Create(ctx context.Context, query string, args []any) (uint64, error)
+
+	Read(ctx context.Context, query string, args []any, dest []any) error
+
+	// Query appends every row to dest, a pointer to a slice of structs whose
+	// fields are mapped to the columns by their db tags.
+	Query(ctx context.Context, query string, args []any, dest any) error
+
+	// Iterate calls fn for every row as they are streamed from the database,
+	// the iteration stops at the first error returned by fn.
+	Iterate(ctx context.Context, query string, args []any, fn func(row Row) error) error
+
+	Update(ctx context.Context, query string, args []any) error
+
+	Delete(ctx context.Context, query string, args []any) error
+
+	Dialect() Dialect
+}
+
 type RDBMS interface {
-	MigrateUp(source string) error
+	Executor
 
-	MigrateDown(source string) error
+	// Migrate runs fn with a migrate instance of the migrations in the dir of
+	// files, it holds an advisory lock meanwhile so concurrent replicas run
+	// their migrations one after another.
+	Migrate(ctx context.Context, files fs.FS, dir string, fn func(m *migrate.Migrate) error) error
 
-	Create(query string, args []any) (uint64, error)
+	// WithTx runs fn in a transaction with the configured isolation level, the
+	// transaction is committed when fn returns nil and rolled back otherwise.
+	// fn is retried when the transaction fails with ErrSerialization, so it
+	// must not have side effects outside of the transaction.
+	WithTx(ctx context.Context, fn func(tx Executor) error) error
 
-	Read(query string, args []any, dest []any) error
+	// Ping checks the database is reachable
+	Ping(ctx context.Context) error
 
-	Update(query string, args []any) error
+	// Collector exposes the stats of the connection pool to prometheus
+	Collector() prometheus.Collector
+
+	// Close closes the connections, the statements in flight are waited for
+	Close() error
+}
+
+// New connects to the database of the configured driver
+func New(cfg *Config) (RDBMS, error) {
+	switch cfg.Driver {
+	case DriverPostgres, "":
+		return NewPostgres(cfg)
+	case DriverMysql:
+		return NewMysql(cfg)
+	case DriverSqlite:
+		return NewSqlite(cfg)
+	default:
+		return nil, fmt.Errorf("unknown rdbms driver %q", cfg.Driver)
+	}
+}
 
-	Delete(query string, args []any) error
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type executor struct {
+	querier  querier
+	dialect  Dialect
+	classify classifier
 }
 
 type rdbms struct {
-	db *sql.DB
+	executor
+	db      *sql.DB
+	options *sql.TxOptions
+	retries int
 }
 
-var (
-	ErrPrepareStatement = "error when tying to prepare statement"
+func newRDBMS(db *sql.DB, cfg *Config, dialect Dialect, classify classifier) (*rdbms, error) {
+	isolation, err := ParseIsolation(cfg.Isolation)
+	if err != nil {
+		return nil, err
+	}
 
-	ErrCreate    = "error when tying to create entry"
-	ErrDuplicate = "entry exists"
+	return &rdbms{
+		executor: executor{querier: db, dialect: dialect, classify: classify},
+		db:       db,
+		options:  &sql.TxOptions{Isolation: isolation},
+		retries:  cfg.TxRetries,
+	}, nil
+}
 
-	ErrRead         = "error when tying to read entry"
-	ErrReadNotFound = "there is no entry with provided arguments"
+func (db *rdbms) Ping(ctx context.Context) error {
+	return db.db.PingContext(ctx)
+}
 
-	ErrUpdate = "error when tying to update entry"
+func (db *rdbms) Close() error {
+	return db.db.Close()
+}
 
-	ErrDelete = "error when tying to delete entry"
-)
+func (db *executor) Dialect() Dialect {
+	return db.dialect
+}
 
-func (db *rdbms) Create(query string, args []any) (uint64, error) {
-	stmt, err := db.db.Prepare(query)
-	if err != nil {
-		return 0, fmt.Errorf("%s\n%v", ErrPrepareStatement, err)
+func (db *executor) Create(ctx context.Context, query string, args []any) (id uint64, err error) {
+	ctx, done := db.observe(ctx, OperationCreate, query)
+	defer done(&err)
+
+	if !db.dialect.Returning() {
+		query, args = db.dialect.Rebind(withoutReturning(query), args)
+
+		result, err := db.querier.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, db.wrap(ErrCreate, err)
+		}
+
+		lastInsertId, err := result.LastInsertId()
+		if err != nil {
+			return 0, db.wrap(ErrCreate, err)
+		}
+
+		return uint64(lastInsertId), nil
 	}
-	defer stmt.Close()
+
+	query, args = db.dialect.Rebind(query, args)
 
 	var lastInsertId int
-	if err = stmt.QueryRow(args...).Scan(&lastInsertId); err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
-			return 0, fmt.Errorf("%s\n%v", ErrDuplicate, err)
+	if err := db.querier.QueryRowContext(ctx, query, args...).Scan(&lastInsertId); err != nil {
+		return 0, db.wrap(ErrCreate, err)
+	}
+
+	return uint64(lastInsertId), nil
+}
+
+func (db *executor) Read(ctx context.Context, query string, args []any, dest []any) (err error) {
+	ctx, done := db.observe(ctx, OperationRead, query)
+	defer done(&err)
+
+	query, args = db.dialect.Rebind(query, args)
+
+	if err := db.querier.QueryRowContext(ctx, query, args...).Scan(dest...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReadNotFound
 		}
 
-		return 0, fmt.Errorf("%s\n%v", ErrCreate, err)
+		return db.wrap(ErrRead, err)
 	}
 
-	return uint64(lastInsertId), nil
+	return nil
 }
 
-func (db *rdbms) Read(query string, args []any, dest []any) error {
-	stmt, err := db.db.Prepare(query)
+func (db *executor) Query(ctx context.Context, query string, args []any, dest any) error {
+	scan, err := appendTo(dest)
 	if err != nil {
-		return fmt.Errorf("%s\n%v", ErrPrepareStatement, err)
+		return err
 	}
-	defer stmt.Close()
 
-	result := stmt.QueryRow(args...)
-	err = result.Scan(dest...)
+	return db.Iterate(ctx, query, args, scan)
+}
+
+func (db *executor) Iterate(ctx context.Context, query string, args []any, fn func(row Row) error) (err error) {
+	ctx, done := db.observe(ctx, OperationIterate, query)
+	defer done(&err)
+
+	query, args = db.dialect.Rebind(query, args)
+
+	rows, err := db.querier.QueryContext(ctx, query, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New(ErrReadNotFound)
+		return db.wrap(ErrRead, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
 		}
+	}
 
-		return fmt.Errorf("%s\n%v", ErrRead, err)
+	if err := rows.Err(); err != nil {
+		return db.wrap(ErrRead, err)
 	}
 
 	return nil
 }
 
-func (db *rdbms) Update(query string, args []any) error {
-	stmt, err := db.db.Prepare(query)
-	if err != nil {
-		return fmt.Errorf("%s\n%v", ErrPrepareStatement, err)
-	}
-	defer stmt.Close()
+func (db *executor) Update(ctx context.Context, query string, args []any) (err error) {
+	ctx, done := db.observe(ctx, OperationUpdate, query)
+	defer done(&err)
+
+	query, args = db.dialect.Rebind(query, args)
 
-	if _, err = stmt.Exec(args); err != nil {
-		return fmt.Errorf("%s\n%v", ErrUpdate, err)
+	if _, err := db.querier.ExecContext(ctx, query, args...); err != nil {
+		return db.wrap(ErrUpdate, err)
 	}
 
 	return nil
 }
 
-func (db *rdbms) Delete(query string, args []any) error {
-	stmt, err := db.db.Prepare(query)
-	if err != nil {
-		return fmt.Errorf("%s\n%v", ErrPrepareStatement, err)
-	}
-	defer stmt.Close()
+func (db *executor) Delete(ctx context.Context, query string, args []any) (err error) {
+	ctx, done := db.observe(ctx, OperationDelete, query)
+	defer done(&err)
+
+	query, args = db.dialect.Rebind(query, args)
 
-	if _, err = stmt.Exec(args...); err != nil {
-		return fmt.Errorf("%s\n%v", ErrDelete, err)
+	if _, err := db.querier.ExecContext(ctx, query, args...); err != nil {
+		return db.wrap(ErrDelete, err)
 	}
 
 	return nil
@@ -0,0 +1,125 @@
+package rdbms
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Row is the current row of Iterate, it's implemented by *sql.Rows
+type Row interface {
+	Columns() ([]string, error)
+
+	Scan(dest ...any) error
+}
+
+// Scan scans the current row into a new T, a struct whose fields are mapped
+// to the columns by their db tags, e.g. `db:"first_name"`.
+func Scan[T any](row Row) (*T, error) {
+	item := new(T)
+	if err := scanStruct(row, reflect.ValueOf(item)); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// fieldsCache holds the column to field index mapping of every scanned struct type
+var fieldsCache sync.Map
+
+// fieldsOf maps the db tags of the struct type, and of its embedded structs, to the index of their fields
+func fieldsOf(structType reflect.Type) map[string][]int {
+	if cached, ok := fieldsCache.Load(structType); ok {
+		return cached.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	collectFields(structType, nil, fields)
+
+	cached, _ := fieldsCache.LoadOrStore(structType, fields)
+	return cached.(map[string][]int)
+}
+
+func collectFields(structType reflect.Type, parent []int, fields map[string][]int) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		index := append(append(make([]int, 0, len(parent)+1), parent...), i)
+
+		tag, ok := field.Tag.Lookup("db")
+		if !ok && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectFields(field.Type, index, fields)
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if !ok || name == "-" || len(name) == 0 || !field.IsExported() {
+			continue
+		}
+
+		if _, exists := fields[name]; !exists {
+			fields[name] = index
+		}
+	}
+}
+
+// scanStruct scans the current row into the pointer to a struct
+func scanStruct(row Row, pointer reflect.Value) error {
+	if pointer.Kind() != reflect.Pointer || pointer.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: expected a pointer to a struct, got %s", ErrRead, pointer.Type())
+	}
+
+	columns, err := row.Columns()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	value := pointer.Elem()
+	fields := fieldsOf(value.Type())
+
+	dest := make([]any, 0, len(columns))
+	for _, column := range columns {
+		index, ok := fields[column]
+		if !ok {
+			return fmt.Errorf("%w: no field of %s has the db tag %q", ErrRead, value.Type(), column)
+		}
+
+		dest = append(dest, value.FieldByIndex(index).Addr().Interface())
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		return fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	return nil
+}
+
+// appendTo returns the function which scans every row into a new item of the
+// slice, dest is a pointer to a slice of structs or of pointers to structs.
+func appendTo(dest any) (func(row Row) error, error) {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Pointer || slice.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("%w: expected a pointer to a slice, got %T", ErrRead, dest)
+	}
+	slice = slice.Elem()
+
+	elemType := slice.Type().Elem()
+	isPointer := elemType.Kind() == reflect.Pointer
+	if isPointer {
+		elemType = elemType.Elem()
+	}
+
+	return func(row Row) error {
+		item := reflect.New(elemType)
+		if err := scanStruct(row, item); err != nil {
+			return err
+		}
+
+		if !isPointer {
+			item = item.Elem()
+		}
+
+		slice.Set(reflect.Append(slice, item))
+		return nil
+	}, nil
+}
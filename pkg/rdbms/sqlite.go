@@ -0,0 +1,87 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Memory is the Path of a database which lives as long as the process
+const Memory = ":memory:"
+
+type sqliteWrapper struct {
+	*rdbms
+}
+
+// NewSqlite opens the database file of the Path, it's created when missing,
+// or an in-memory database when the Path is Memory.
+func NewSqlite(cfg *Config) (RDBMS, error) {
+	if len(cfg.Path) == 0 {
+		return nil, errors.New("Error no path has been given for the sqlite database")
+	}
+
+	connString := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", cfg.Path)
+
+	db, err := sql.Open("sqlite", connString)
+	if err != nil {
+		return nil, fmt.Errorf("Error openning connection:\n%v", err)
+	}
+
+	// sqlite serializes the writes anyway, and every connection to :memory:
+	// would open a database of its own
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Error ping database:\n%v", err)
+	}
+
+	rdbms, err := newRDBMS(db, cfg, sqliteDialect{}, classifySqlite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteWrapper{rdbms}, nil
+}
+
+// classifySqlite maps the result codes of https://www.sqlite.org/rescode.html
+func classifySqlite(err error) error {
+	var sqliteErr *driver.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return ErrDuplicate
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return ErrForeignKey
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		return ErrNotNull
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return ErrSerialization
+	default:
+		return nil
+	}
+}
+
+// Migrate needs no lock since sqlite allows a single writer at a time, the
+// instance is not closed since it would close the database as well.
+func (db *sqliteWrapper) Migrate(ctx context.Context, files fs.FS, dir string, fn func(*migrate.Migrate) error) error {
+	instance, err := sqlite.WithInstance(db.db, &sqlite.Config{})
+	if err != nil {
+		return fmt.Errorf("Error creating migrate instance\n%v", err)
+	}
+
+	return runMigrate(files, dir, "sqlite", instance, fn)
+}
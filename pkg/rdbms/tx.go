@@ -0,0 +1,80 @@
+package rdbms
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Isolation levels of the Config, an empty level uses the default of the database
+const (
+	IsolationReadCommitted  = "read_committed"
+	IsolationRepeatableRead = "repeatable_read"
+	IsolationSerializable   = "serializable"
+)
+
+// retryDelay is the delay before the first retry of a transaction, it's doubled for every retry
+const retryDelay = 10 * time.Millisecond
+
+// ParseIsolation returns the level of the Config, case insensitively, an empty
+// level is the default of the database.
+func ParseIsolation(level string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(level) {
+	case "":
+		return sql.LevelDefault, nil
+	case IsolationReadCommitted:
+		return sql.LevelReadCommitted, nil
+	case IsolationRepeatableRead:
+		return sql.LevelRepeatableRead, nil
+	case IsolationSerializable:
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", level)
+	}
+}
+
+func (db *rdbms) WithTx(ctx context.Context, fn func(tx Executor) error) error {
+	delay := retryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := db.transaction(ctx, fn)
+		if err == nil || !errors.Is(err, ErrSerialization) || attempt >= db.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
+
+func (db *rdbms) transaction(ctx context.Context, fn func(tx Executor) error) (err error) {
+	tx, err := db.db.BeginTx(ctx, db.options)
+	if err != nil {
+		return db.wrap(ErrBegin, err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&executor{querier: tx, dialect: db.dialect, classify: db.classify}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return db.wrap(ErrCommit, err)
+	}
+
+	return nil
+}
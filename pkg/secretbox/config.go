@@ -0,0 +1,6 @@
+package secretbox
+
+type Config struct {
+	// Key is the base64 encoded 32 byte key of AES-256
+	Key string `koanf:"key" redact:"true"`
+}
@@ -0,0 +1,70 @@
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCiphertext = errors.New("ciphertext is malformed or has been tampered with")
+
+// SecretBox encrypts small secrets, like the totp secrets, before they are stored
+type SecretBox interface {
+	Seal(plaintext string) (string, error)
+
+	Open(ciphertext string) (string, error)
+}
+
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+func New(cfg *Config) (SecretBox, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding encryption key: %w", err)
+	} else if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, %d bytes given", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating gcm: %w", err)
+	}
+
+	return &secretBox{aead: aead}, nil
+}
+
+// Seal returns base64(nonce || ciphertext)
+func (box *secretBox) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, box.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	sealed := box.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (box *secretBox) Open(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < box.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:box.aead.NonceSize()], sealed[box.aead.NonceSize():]
+	plaintext, err := box.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
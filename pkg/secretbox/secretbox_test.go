@@ -0,0 +1,102 @@
+package secretbox
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func newBox(t *testing.T) SecretBox {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	box, err := New(&Config{Key: base64.StdEncoding.EncodeToString(key)})
+	if err != nil {
+		t.Fatalf("creating secret box: %v", err)
+	}
+
+	return box
+}
+
+func seal(t *testing.T, box SecretBox, plaintext string) string {
+	t.Helper()
+
+	sealed, err := box.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("sealing: %v", err)
+	}
+
+	return sealed
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "empty", key: ""},
+		{name: "not base64", key: "not a key!"},
+		{name: "short", key: base64.StdEncoding.EncodeToString(make([]byte, 16))},
+		{name: "long", key: base64.StdEncoding.EncodeToString(make([]byte, 64))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := New(&Config{Key: test.key}); err == nil {
+				t.Errorf("expected an error for the key %q", test.key)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	box := newBox(t)
+
+	for _, plaintext := range []string{"", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"} {
+		opened, err := box.Open(seal(t, box, plaintext))
+		if err != nil {
+			t.Fatalf("opening: %v", err)
+		} else if opened != plaintext {
+			t.Errorf("expected %q, got %q", plaintext, opened)
+		}
+	}
+
+	// every seal has its own nonce
+	if seal(t, box, "secret") == seal(t, box, "secret") {
+		t.Errorf("expected different ciphertexts of the same plaintext")
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	box := newBox(t)
+	sealed := seal(t, box, "secret")
+
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	tampered := append([]byte{}, raw...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name       string
+		box        SecretBox
+		ciphertext string
+	}{
+		{name: "tampered", box: box, ciphertext: base64.StdEncoding.EncodeToString(tampered)},
+		{name: "truncated", box: box, ciphertext: base64.StdEncoding.EncodeToString(raw[:len(raw)-1])},
+		{name: "shorter than the nonce", box: box, ciphertext: base64.StdEncoding.EncodeToString(raw[:4])},
+		{name: "not base64", box: box, ciphertext: "not base64!"},
+		{name: "wrong key", box: newBox(t), ciphertext: sealed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.box.Open(test.ciphertext); !errors.Is(err, ErrInvalidCiphertext) {
+				t.Errorf("expected %v, got %v", ErrInvalidCiphertext, err)
+			}
+		})
+	}
+}
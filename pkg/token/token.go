@@ -0,0 +1,31 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// number of random bytes in every generated token
+const size = 32
+
+// Generate returns a random url safe token and its hash, only the hash should
+// be persisted so a leaked database can not be used to redeem the tokens.
+func Generate() (plain string, hash string, err error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", "", fmt.Errorf("error generating random token: %w", err)
+	}
+
+	plain = base64.RawURLEncoding.EncodeToString(buffer)
+	return plain, Hash(plain), nil
+}
+
+// Hash returns the hex encoded SHA-256 of the token, the tokens have enough
+// entropy that a slow password hash is not needed.
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,38 @@
+package totp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+const (
+	recoveryAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	recoveryLength   = 10
+)
+
+// GenerateRecoveryCodes returns count one-time codes formatted as XXXXX-XXXXX
+func GenerateRecoveryCodes(count int) ([]string, error) {
+	codes := make([]string, 0, count)
+
+	buffer := make([]byte, recoveryLength)
+	for i := 0; i < count; i++ {
+		if _, err := rand.Read(buffer); err != nil {
+			return nil, fmt.Errorf("error generating recovery code: %w", err)
+		}
+
+		code := make([]byte, recoveryLength)
+		for j, b := range buffer {
+			code[j] = recoveryAlphabet[int(b)%len(recoveryAlphabet)]
+		}
+
+		codes = append(codes, fmt.Sprintf("%s-%s", code[:recoveryLength/2], code[recoveryLength/2:]))
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode makes the code comparable regardless of the case and separators the user typed
+func NormalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(code))
+}
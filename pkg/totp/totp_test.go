@@ -0,0 +1,127 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the base32 encoded SHA1 secret of the test vectors of RFC 6238,
+// "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// the RFC gives 8 digits, the codes are their last 6
+	tests := []struct {
+		time     int64
+		expected string
+	}{
+		{time: 59, expected: "287082"},
+		{time: 1111111109, expected: "081804"},
+		{time: 1111111111, expected: "050471"},
+		{time: 1234567890, expected: "005924"},
+		{time: 2000000000, expected: "279037"},
+		{time: 20000000000, expected: "353130"},
+	}
+
+	for _, test := range tests {
+		t.Run(time.Unix(test.time, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			code, err := Code(rfcSecret, Step(time.Unix(test.time, 0)))
+			if err != nil {
+				t.Fatalf("generating code: %v", err)
+			} else if code != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, code)
+			}
+		})
+	}
+}
+
+func TestCodeSecretFormat(t *testing.T) {
+	for _, secret := range []string{strings.ToLower(rfcSecret), rfcSecret + "===="} {
+		if code, err := Code(secret, 1); err != nil || code != "287082" {
+			t.Errorf("expected %s for %q, got %s and %v", "287082", secret, code, err)
+		}
+	}
+
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Errorf("expected an error for an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	code := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("generating code: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name string
+		code string
+		skew int64
+		step int64
+		ok   bool
+	}{
+		{name: "current", code: code(current), step: current, ok: true},
+		{name: "surrounding spaces", code: " " + code(current) + " ", step: current, ok: true},
+		{name: "previous without skew", code: code(current - 1)},
+		{name: "previous", code: code(current - 1), skew: 1, step: current - 1, ok: true},
+		{name: "next", code: code(current + 1), skew: 1, step: current + 1, ok: true},
+		{name: "beyond the skew", code: code(current - 2), skew: 1},
+		{name: "wrong code", code: "000000", skew: 1},
+		{name: "short code", code: code(current)[:5]},
+		{name: "long code", code: code(current) + "0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, test.code, now, test.skew)
+			if ok != test.ok || step != test.step {
+				t.Errorf("expected step %d and %v, got %d and %v", test.step, test.ok, step, ok)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generating secret: %v", err)
+	}
+
+	if key, err := encoding.DecodeString(secret); err != nil || len(key) != secretSize {
+		t.Errorf("expected a %d byte base32 secret, got %q", secretSize, secret)
+	}
+
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("expected the secret to generate codes, got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("generating recovery codes: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != recoveryLength+1 || code[recoveryLength/2] != '-' {
+			t.Errorf("expected the XXXXX-XXXXX format, got %q", code)
+		}
+		seen[code] = true
+	}
+
+	if len(codes) != 10 || len(seen) != 10 {
+		t.Errorf("expected 10 distinct codes, got %v", codes)
+	}
+
+	if normalized := NormalizeRecoveryCode(" abcde-fghjk "); normalized != "ABCDEFGHJK" {
+		t.Errorf("expected %q, got %q", "ABCDEFGHJK", normalized)
+	}
+}
@@ -0,0 +1,18 @@
+package tracing
+
+type Config struct {
+	// Exporter is either none, otlp, stdout or file
+	Exporter    string `koanf:"exporter"`
+	ServiceName string `koanf:"service_name"`
+
+	// SampleRatio of the traces started here, the sampling decision of the
+	// callers is respected for the traces they have started.
+	SampleRatio float64 `koanf:"sample_ratio"`
+
+	// Endpoint of the OTLP gRPC collector, e.g. localhost:4317
+	Endpoint string `koanf:"endpoint"`
+	Insecure bool   `koanf:"insecure"`
+
+	// File the spans are appended to as JSON by the file exporter
+	File string `koanf:"file"`
+}